@@ -60,3 +60,10 @@ func (t *testLogger) Printf(format string, args ...interface{}) {
 func newTestLogger(t *testing.T) Logger {
 	return &testLogger{t}
 }
+
+// requireTags skips test relying on entry tags when header profile does not keep them.
+func requireTags(t *testing.T) {
+	if !headerHasTag {
+		t.Skip("entry header profile keeps no tags")
+	}
+}
@@ -0,0 +1,84 @@
+package bigcache
+
+// GetMultiHashed reads entries for already hashed keys, locking every shard once rather than once per key.
+// Returned map has copies of entries found, hashes without entries are not in it.
+func (c *BigCache) GetMultiHashed(hashedKeys []uint64) map[uint64][]byte {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	found := make(map[uint64][]byte, len(hashedKeys))
+	for i, hashes := range c.groupByShard(hashedKeys) {
+		if len(hashes) > 0 {
+			c.shards[i].getMulti(hashes, found)
+		}
+	}
+	return found
+}
+
+// DeleteMultiHashed removes entries for already hashed keys, locking every shard once rather than once per key.
+// It returns number of entries removed. Nothing is removed from frozen cache.
+func (c *BigCache) DeleteMultiHashed(hashedKeys []uint64) int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return 0
+	}
+	var deleted int
+	for i, hashes := range c.groupByShard(hashedKeys) {
+		if len(hashes) > 0 {
+			deleted += c.shards[i].delMulti(hashes)
+		}
+	}
+	return deleted
+}
+
+// groupByShard splits hashes by index of the shard keeping them.
+// NOTE: layout lock must be held.
+func (c *BigCache) groupByShard(hashedKeys []uint64) [][]uint64 {
+	groups := make([][]uint64, len(c.shards))
+	for _, hash := range hashedKeys {
+		i := c.shardIndex(hash)
+		groups[i] = append(groups[i], hash)
+	}
+	return groups
+}
+
+// getMulti adds copies of entries found for hashes to found.
+func (s *cacheShard) getMulti(hashes []uint64, found map[uint64][]byte) {
+
+	defer s.readUnlock(s.readLock(hashes[0]))
+
+	for _, hash := range hashes {
+		if s.filter != nil && !s.filter.mayContain(hash) {
+			s.miss()
+			continue
+		}
+		if data, err := s.getWithoutLock(nil, hash, nil, false); err == nil {
+			found[hash] = data
+		}
+	}
+}
+
+// delMulti removes entries for hashes returning number of entries removed.
+func (s *cacheShard) delMulti(hashes []uint64) int {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var deleted int
+	for _, hash := range hashes {
+		ref, found, _ := s.find(nil, hash)
+		if !found {
+			s.delmiss()
+			continue
+		}
+		if err := s.delWithoutLock(hash, ref); err != nil {
+			s.delmiss()
+			continue
+		}
+		s.delhit()
+		deleted++
+	}
+	return deleted
+}
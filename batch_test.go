@@ -0,0 +1,75 @@
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetMultiHashed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             8,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	var hashes []uint64
+	for i := 0; i < 64; i++ {
+		hash := uint64(i)
+		cache.SetHashed(hash, []byte(fmt.Sprintf("value%d", i)))
+		hashes = append(hashes, hash)
+	}
+
+	// when
+	found := cache.GetMultiHashed(append(hashes, 100, 101))
+
+	// then
+	assertEqual(t, 64, len(found))
+	for i, hash := range hashes {
+		assertEqual(t, []byte(fmt.Sprintf("value%d", i)), found[hash])
+	}
+	_, ok := found[100]
+	assertEqual(t, false, ok)
+	assertEqual(t, int64(64), cache.Stats().Hits)
+	assertEqual(t, int64(2), cache.Stats().Misses)
+}
+
+func TestDeleteMultiHashed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             8,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 64; i++ {
+		cache.SetHashed(uint64(i), []byte("value"))
+	}
+	var odd []uint64
+	for i := 1; i < 64; i += 2 {
+		odd = append(odd, uint64(i))
+	}
+
+	// when
+	deleted := cache.DeleteMultiHashed(append(odd, 100, 101))
+
+	// then
+	assertEqual(t, 32, deleted)
+	assertEqual(t, 32, cache.Len())
+	assertEqual(t, 0, len(cache.GetMultiHashed(odd)))
+	assertEqual(t, int64(32), cache.Stats().DelHits)
+	assertEqual(t, int64(2), cache.Stats().DelMisses)
+
+	// when
+	cache.Freeze()
+	deleted = cache.DeleteMultiHashed([]uint64{0, 2})
+
+	// then
+	assertEqual(t, 0, deleted)
+	assertEqual(t, 32, cache.Len())
+}
@@ -1,26 +1,113 @@
 package bigcache
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	ErrEntryNotFound       = errors.New("entry not found")
-	ErrInvalidShardsNumber = errors.New("invalid number of shards, must be power of two")
+	ErrEntryNotFound        = errors.New("entry not found")
+	ErrInvalidShardsNumber  = errors.New("invalid number of shards, must be power of two")
+	ErrInvalidWatermark     = errors.New("invalid eviction watermark, must be in (0, 1] range")
+	ErrBufferTooSmall       = errors.New("buffer is too small for entry")
+	ErrCacheFrozen          = errors.New("cache is frozen")
+	ErrInvalidShardIndex    = errors.New("invalid shard index")
+	ErrInvalidPreallocation = errors.New("invalid preallocation, HardMaxCacheSize must be set")
+	ErrKeyTooLong           = errors.New("key is too long")
+	ErrInvalidMinEntries    = errors.New("invalid minimum number of entries per shard, must not be negative")
+	ErrInvalidHashSeed      = errors.New("hash seed could only be used with default hasher")
+	ErrHeaderField          = errors.New("entry header profile does not have the field")
+	ErrCacheClosed          = errors.New("cache is closed")
+	ErrCleanupStopped       = errors.New("background cleanup stopped")
+	ErrShardCorrupted       = errors.New("shard is corrupted")
+	ErrMalformedSet         = errors.New("entry is not a set of members")
+	ErrLoaderPanicked       = errors.New("loader panicked")
+	// ErrEntryNegativeCached is returned for keys marked as absent with SetMiss(). It wraps ErrEntryNotFound,
+	// so errors.Is(err, ErrEntryNotFound) holds for it.
+	ErrEntryNegativeCached = fmt.Errorf("%w: key is known to be absent", ErrEntryNotFound)
 )
 
+// NotFoundError is returned by Get and Delete family of methods when there is no entry for the key. It wraps
+// ErrEntryNotFound, so errors.Is(err, ErrEntryNotFound) holds for it. Key is empty for already hashed keys.
+type NotFoundError struct {
+	Key string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Key == usingAlreadyHashedKey {
+		return ErrEntryNotFound.Error()
+	}
+	return fmt.Sprintf("%v: %q", ErrEntryNotFound, e.Key)
+}
+
+// Unwrap returns ErrEntryNotFound.
+func (e *NotFoundError) Unwrap() error {
+	return ErrEntryNotFound
+}
+
+// notFoundError replaces ErrEntryNotFound returned by shard with NotFoundError carrying the key,
+// other errors are returned as is.
+func notFoundError(key string, err error) error {
+	if err == ErrEntryNotFound {
+		return &NotFoundError{Key: key}
+	}
+	return err
+}
+
+// EntryInfo describes cached entry header.
+type EntryInfo struct {
+	Timestamp uint64        // time entry was stored at, in cache clock units
+	Tag       uint16        // user metadata set by SetWithTag
+	Age       time.Duration // time passed since entry was stored, with cache clock resolution (one second)
+	TTL       time.Duration // time left until entry expires according to LifeWindow, 0 when it is already expired
+}
+
+// newEntryInfo computes information of entry stored at ts.
+func newEntryInfo(ts uint64, tag uint16, current, lifeWindow uint64) EntryInfo {
+	info := EntryInfo{Timestamp: ts, Tag: tag}
+	var age uint64
+	if current > ts {
+		// timestamps from the future, i.e. after clock moved back, have no age
+		age = current - ts
+	}
+	info.Age = time.Duration(age) * time.Second
+	if age < lifeWindow {
+		info.TTL = time.Duration(lifeWindow-age) * time.Second
+	}
+	return info
+}
+
 // BigCache is fast, concurrent, evicting cache created to keep big number of entries without impact on performance.
 // It keeps entries on heap but omits GC for them. To achieve that, operations take place on byte arrays,
 // therefore entries (de)serialization in front of the cache will be needed in most use cases.
 type BigCache struct {
-	shards       []*cacheShard
-	clock        clock
-	hash         Hasher
-	config       Config
-	shardMask    uint64
-	maxShardSize uint32
-	close        chan struct{}
+	layout        sync.RWMutex // protects shards layout, write locked by Resize only
+	shards        []*cacheShard
+	clock         clock
+	hash          Hasher
+	config        Config // immutable after construction, its Shards is the initial number of shards
+	shardMask     uint64 // changed by Resize along with shards
+	selector      ShardSelector
+	maxShardSize  uint32
+	events        *eventBroker
+	wal           *writeAheadLog
+	limit         *entryLimit
+	validator     func(key string) error
+	snapshot      sync.Mutex // serializes snapshots
+	loads         loadGroup
+	refreshing    refreshSet
+	refreshWindow uint64 // remaining life time in seconds which triggers refresh ahead
+	paused        int32
+	closed        int32
+	cleaning      int32 // set while background cleanup goroutine runs
+	frozen        int32 // changed under layout write lock, so it is stable while read lock is held
+	resized       Stats // statistics accumulated by shards replaced by Resize
+	close         chan struct{}
 }
 
 // Processor is a closure supplied to set of WithProcessing functions to take ownership of data []byte avoiding extra memory
@@ -28,17 +115,72 @@ type BigCache struct {
 // (not a copy!).
 type Processor func(*CacheEntry) error
 
+// Cache is a set of operations provided by BigCache. Depend on it rather than on *BigCache when implementation
+// needs to be replaced, i.e. by mock in tests.
+type Cache interface {
+	Get(key string) ([]byte, error)
+	Set(key string, entry []byte) error
+	Append(key string, entry []byte) error
+	Delete(key string) error
+	Reset() error
+	Len() int
+	Capacity() int
+	Stats() Stats
+	Close() error
+	Range(f Processor) error
+}
+
+// this is a safeguard, breaking on compile time in case BigCache does not implement Cache interface.
+var _ Cache = (*BigCache)(nil)
+
 // NewBigCache initializes new instance of BigCache.
 func NewBigCache(config Config) (*BigCache, error) {
+	if config.Clock != nil {
+		return newBigCache(config, userClock{config.Clock})
+	}
 	return newBigCache(config, &systemClock{})
 }
 
+// MustNewBigCache is like NewBigCache but panics if cache cannot be created, i.e. because of invalid configuration.
+// It simplifies initialization of global variables and tests.
+func MustNewBigCache(config Config) *BigCache {
+	cache, err := NewBigCache(config)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
 func newBigCache(config Config, clock clock) (*BigCache, error) {
 
+	if config.Shards == 0 {
+		config.Shards = autoShards()
+	}
 	if !isPowerOfTwo(config.Shards) {
 		return nil, ErrInvalidShardsNumber
 	}
+	if config.EvictionWatermark < 0 || config.EvictionWatermark > 1 {
+		return nil, ErrInvalidWatermark
+	}
+	if config.PreallocateToMax && config.HardMaxCacheSize <= 0 {
+		return nil, ErrInvalidPreallocation
+	}
+	if config.MinEntriesPerShard < 0 {
+		return nil, ErrInvalidMinEntries
+	}
+	if config.IdleTimeout > 0 && !headerHasAccess {
+		return nil, fmt.Errorf("%w: idle timeout needs time of the last access, not kept with %s profile", ErrHeaderField, headerProfile)
+	}
+	if config.Cipher != nil && config.Cipher.NonceSize() > maxNonceLength {
+		return nil, fmt.Errorf("%w: nonce of %d bytes does not fit into entry header", ErrHeaderField, config.Cipher.NonceSize())
+	}
 
+	if config.HashSeed != 0 {
+		if _, standard := config.Hasher.(fnv64a); !standard && config.Hasher != nil {
+			return nil, ErrInvalidHashSeed
+		}
+		config.Hasher = newSeededHasher(config.HashSeed)
+	}
 	if config.Hasher == nil {
 		config.Hasher = newDefaultHasher()
 	}
@@ -47,103 +189,573 @@ func newBigCache(config Config, clock clock) (*BigCache, error) {
 	}
 
 	cache := &BigCache{
-		shards:       make([]*cacheShard, config.Shards),
-		clock:        clock,
-		hash:         config.Hasher,
-		config:       config,
-		shardMask:    uint64(config.Shards - 1),
-		maxShardSize: uint32(config.maximumShardSizeInBytes()),
-		close:        make(chan struct{}),
+		shards:        make([]*cacheShard, config.Shards),
+		clock:         clock,
+		hash:          config.Hasher,
+		config:        config,
+		shardMask:     uint64(config.Shards - 1),
+		selector:      config.ShardSelector,
+		maxShardSize:  uint32(config.maximumShardSizeInBytes()),
+		refreshWindow: config.refreshAheadWindow(),
+		events:        newEventBroker(config.EventBufferSize),
+		limit:         newEntryLimit(config.GlobalMaxEntries),
+		validator:     config.KeyValidator,
+		close:         make(chan struct{}),
 	}
 
 	for i := 0; i < config.Shards; i++ {
-		cache.shards[i] = initNewShard(config, clock)
+		shard, err := initNewShard(i, config, clock, cache.events, nil, cache.limit)
+		if err != nil {
+			return nil, err
+		}
+		cache.shards[i] = shard
 	}
 
 	if config.CleanWindow > 0 {
+		atomic.StoreInt32(&cache.cleaning, 1)
 		go func() {
+			defer func() {
+				atomic.StoreInt32(&cache.cleaning, 0)
+				if r := recover(); r != nil {
+					leveled(config.Logger).Errorf("background cleanup stopped: %v", r)
+				}
+			}()
 			ticker := time.NewTicker(config.CleanWindow)
 			defer ticker.Stop()
 			for {
 				select {
-				case t := <-ticker.C:
-					cache.cleanUp(uint64(t.Unix()))
+				case <-ticker.C:
+					if atomic.LoadInt32(&cache.paused) == 0 {
+						cache.cleanUp(cache.clock.epoch())
+					}
 				case <-cache.close:
 					return
 				}
 			}
 		}()
 	}
+	if h := config.MemoryPressureHandler; h != nil && h.Interval > 0 {
+		go cache.watchMemoryPressure(*h)
+	}
+	if config.ReclaimInterval > 0 {
+		go cache.every(config.ReclaimInterval, func() { cache.reclaimTombstones() })
+	}
+	cache.warmUp(config.Warmup)
+	if config.SnapshotPath != "" {
+		if err := cache.loadSnapshot(); err != nil {
+			close(cache.close)
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+		if config.SnapshotInterval > 0 {
+			go cache.every(config.SnapshotInterval, func() {
+				if err := cache.saveSnapshot(); err != nil {
+					leveled(config.Logger).Errorf("unable to save snapshot: %v", err)
+				}
+			})
+		}
+	}
+	if config.WALPath != "" {
+		if err := cache.openWAL(); err != nil {
+			close(cache.close)
+			return nil, fmt.Errorf("write-ahead log: %w", err)
+		}
+	}
 	return cache, nil
 }
 
+// warmUp stores entries like Set does, entries which cannot be stored are logged and skipped.
+func (c *BigCache) warmUp(entries map[string][]byte) {
+	for key, entry := range entries {
+		if err := c.Set(key, entry); err != nil {
+			leveled(c.config.Logger).Warnf("unable to warm up entry %q: %v", key, err)
+		}
+	}
+}
+
 // Close is used to signal a shutdown of the cache when you are done with it.
 // This allows the cleaning goroutines to exit and ensures references are not
 // kept to the cache preventing GC of the entire cache.
+// When Config.SnapshotPath is set final snapshot is saved, write-ahead log is flushed and closed.
+// When Config.Storage is set cache is emptied and memory of shard queues is released.
+// Closing cache again returns ErrCacheClosed.
 func (c *BigCache) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return ErrCacheClosed
+	}
 	close(c.close)
-	return nil
+	c.events.close()
+	var err error
+	if c.config.SnapshotPath != "" {
+		// final snapshot waits for periodic one in progress
+		err = c.saveSnapshot()
+	}
+	if c.wal != nil {
+		if werr := c.wal.close(); err == nil {
+			err = werr
+		}
+	}
+	if c.config.Storage != nil {
+		if rerr := c.releaseShards(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// releaseShards empties all shards returning their memory to Config.Storage.
+func (c *BigCache) releaseShards() error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	var err error
+	for _, shard := range c.shards {
+		if rerr := shard.release(c.shardConfig()); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// PauseCleanup makes background cleanup skip its runs until ResumeCleanup is called, i.e. to avoid
+// expiration sweeps competing for shard locks with bulk import. Missed runs are not replayed.
+func (c *BigCache) PauseCleanup() {
+	atomic.StoreInt32(&c.paused, 1)
+}
+
+// CleanupActive reports if background cleanup goroutine is running, it is false when CleanWindow is not set,
+// after Close and when goroutine has stopped because of a panic. Paused cleanup is still active.
+func (c *BigCache) CleanupActive() bool {
+	return atomic.LoadInt32(&c.closed) == 0 && atomic.LoadInt32(&c.cleaning) != 0
+}
+
+// ResumeCleanup restores background cleanup paused by PauseCleanup, next run happens on regular schedule.
+func (c *BigCache) ResumeCleanup() {
+	atomic.StoreInt32(&c.paused, 0)
+}
+
+// Freeze makes cache read-only: Set, Append, Delete, Reset and Resize return ErrCacheFrozen, nothing is evicted and
+// background cleanup is stopped, so entries expired in the meantime are still returned. Reads are not affected.
+// Mutations in progress are completed before Freeze returns.
+func (c *BigCache) Freeze() {
+	c.layout.Lock()
+	defer c.layout.Unlock()
+
+	atomic.StoreInt32(&c.frozen, 1)
+}
+
+// Unfreeze makes cache frozen by Freeze writable again, background cleanup resumes on regular schedule.
+func (c *BigCache) Unfreeze() {
+	c.layout.Lock()
+	defer c.layout.Unlock()
+
+	atomic.StoreInt32(&c.frozen, 0)
+}
+
+func (c *BigCache) isFrozen() bool {
+	return atomic.LoadInt32(&c.frozen) != 0
 }
 
 var usingAlreadyHashedKey = ""
 
 // Get reads entry for the key returning copy of cached data.
-// It returns an ErrEntryNotFound when no entry exists for the given key.
+// It returns a NotFoundError when no entry exists for the given key.
+// When Config.RefreshAhead is set, entries close to expiration are refreshed in background.
 func (c *BigCache) Get(key string) ([]byte, error) {
+	if err := c.validate(key); err != nil {
+		return nil, err
+	}
+	return c.get(key, c.hash.Sum64(key))
+}
+
+// get is Get for already validated key with its hash.
+func (c *BigCache) get(key string, hashedKey uint64) ([]byte, error) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	shard := c.getShard(hashedKey)
+	if c.config.RefreshAhead == nil {
+		data, err := shard.get([]byte(key), hashedKey, nil)
+		return data, notFoundError(key, err)
+	}
+	data, info, err := shard.getWithInfo([]byte(key), hashedKey)
+	if err == nil {
+		c.refreshAhead(key, info.Timestamp)
+	}
+	return data, notFoundError(key, err)
+}
+
+// GetBytes is Get for key kept in byte slice, which saves conversion of the key to string when Hasher implements
+// BytesHasher. The key could be reused when GetBytes returns.
+func (c *BigCache) GetBytes(key []byte) ([]byte, error) {
+	if c.validator != nil {
+		if err := c.validator(string(key)); err != nil {
+			return nil, err
+		}
+	}
+	hashedKey := c.sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	shard := c.getShard(hashedKey)
+	if c.config.RefreshAhead == nil {
+		data, err := shard.get(key, hashedKey, nil)
+		if err != nil {
+			return nil, notFoundError(string(key), err)
+		}
+		return data, nil
+	}
+	data, info, err := shard.getWithInfo(key, hashedKey)
+	if err != nil {
+		return nil, notFoundError(string(key), err)
+	}
+	c.refreshAhead(string(key), info.Timestamp)
+	return data, nil
+}
+
+// Peek reads entry for the key returning copy of cached data like Get does, but without updating statistics,
+// so monitoring and debugging tools do not skew hit ratio. It returns a NotFoundError when no entry exists for the given key.
+func (c *BigCache) Peek(key string) ([]byte, error) {
+	if err := c.validate(key); err != nil {
+		return nil, err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	shard := c.getShard(hashedKey)
+	data, err := shard.peek([]byte(key), hashedKey)
+	return data, notFoundError(key, err)
+}
+
+// Has reports if there is an entry for the key without copying its data. Entries with nil and empty data are
+// present, keys marked as absent with SetMiss() are not. Like Peek it does not update statistics.
+func (c *BigCache) Has(key string) bool {
+	if c.validate(key) != nil {
+		return false
+	}
 	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	return c.getShard(hashedKey).has([]byte(key), hashedKey)
+}
+
+// GetWithInfo reads entry for the key returning copy of cached data along with its header information.
+// It returns a NotFoundError when no entry exists for the given key.
+func (c *BigCache) GetWithInfo(key string) ([]byte, EntryInfo, error) {
+	if err := c.validate(key); err != nil {
+		return nil, EntryInfo{}, err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	shard := c.getShard(hashedKey)
-	return shard.get(key, hashedKey, nil)
+	data, info, err := shard.getWithInfo([]byte(key), hashedKey)
+	return data, info, notFoundError(key, err)
+}
+
+// GetInto reads entry for the key copying cached data into dst, so caller could reuse its buffers avoiding
+// allocations. It returns number of bytes copied or, when dst is not big enough, ErrBufferTooSmall along with
+// required size. It returns a NotFoundError when no entry exists for the given key.
+// NOTE: dst must not alias cache memory, i.e. slice obtained in Processor.
+func (c *BigCache) GetInto(key string, dst []byte) (int, error) {
+	if err := c.validate(key); err != nil {
+		return 0, err
+	}
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	hashedKey := c.hash.Sum64(key)
+	shard := c.getShard(hashedKey)
+	n, err := shard.getInto([]byte(key), hashedKey, dst)
+	return n, notFoundError(key, err)
 }
 
 // GetHashed reads entry for the key returning copy of cached data.
-// It returns an ErrEntryNotFound when no entry exists for the given key.
+// It returns a NotFoundError when no entry exists for the given key.
 // NOTE: it expects already hashed key.
 func (c *BigCache) GetHashed(hashedKey uint64) ([]byte, error) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	shard := c.getShard(hashedKey)
-	return shard.get(usingAlreadyHashedKey, hashedKey, nil)
+	data, err := shard.get(nil, hashedKey, nil)
+	return data, notFoundError(usingAlreadyHashedKey, err)
 }
 
 // GetWithProcessing reads entry for the key.
 // If found it gives provided Processor closure a chance to process cached entry effectively.
-// It returns an ErrEntryNotFound when no entry exists for the given key.
+// It returns a NotFoundError when no entry exists for the given key.
 func (c *BigCache) GetWithProcessing(key string, processor Processor) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
 	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	shard := c.getShard(hashedKey)
-	_, err := shard.get(key, hashedKey, processor)
-	return err
+	_, err := shard.get([]byte(key), hashedKey, processor)
+	return notFoundError(key, err)
+}
+
+// GetJSON decodes JSON entry for the key into v straight from shard buffer, without copying entry data first.
+// It returns a NotFoundError when no entry exists for the given key and decoding error otherwise.
+func (c *BigCache) GetJSON(key string, v interface{}) error {
+	return c.GetWithProcessing(key, func(ce *CacheEntry) error {
+		return json.Unmarshal(ce.Data, v)
+	})
 }
 
 // GetHashedWithProcessing reads entry for the key.
 // If found it gives provided Processor closure a chance to process cached entry effectively.
-// It returns an ErrEntryNotFound when no entry exists for the given key.
+// It returns a NotFoundError when no entry exists for the given key.
 // NOTE: it expects already hashed key.
 func (c *BigCache) GetHashedWithProcessing(hashedKey uint64, processor Processor) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	shard := c.getShard(hashedKey)
-	_, err := shard.get(usingAlreadyHashedKey, hashedKey, processor)
-	return err
+	_, err := shard.get(nil, hashedKey, processor)
+	return notFoundError(usingAlreadyHashedKey, err)
+}
+
+// Hash returns hash of the key computed by configured Hasher, which selects the entry in *Hashed methods. Callers
+// issuing several operations for the same key could hash it once and use *Hashed methods, i.e. GetHashed or
+// DeleteHashed, which find entries stored under the key as well.
+// NOTE: entries stored by *Hashed methods have no key, so keyed methods treat them as colliding with the key and
+// do not see them. Use GetOrComputeHashed to store entry under the key while getting its hash.
+func (c *BigCache) Hash(key string) uint64 {
+	return c.hash.Sum64(key)
+}
+
+// GetOrComputeHashed reads entry for the key and when it is not present stores the one returned by compute,
+// hashing the key only once. Returned hash could be used with *Hashed methods for following operations on the
+// entry. Unlike GetOrLoad concurrent misses are not coalesced, so compute may be called by each of them.
+// Compute errors are returned and never cached, negatively cached keys are reported without calling compute.
+func (c *BigCache) GetOrComputeHashed(key string, compute func() ([]byte, error)) ([]byte, uint64, error) {
+	if err := c.validate(key); err != nil {
+		return nil, 0, err
+	}
+	hashedKey := c.hash.Sum64(key)
+	data, err := c.get(key, hashedKey)
+	if !errors.Is(err, ErrEntryNotFound) || errors.Is(err, ErrEntryNegativeCached) {
+		return data, hashedKey, err
+	}
+	if data, err = compute(); err != nil {
+		return nil, hashedKey, err
+	}
+	return data, hashedKey, c.set(key, hashedKey, data)
+}
+
+// GetOrLoad reads entry for the key and when it is not present calls loader and stores its result.
+// Concurrent misses for the same key are coalesced - only one loader runs and its result is shared
+// by all waiting callers, so returned slice should not be modified. Loader errors are returned and never cached.
+// When loader panics the panic is propagated to the caller which ran it, waiting callers get ErrLoaderPanicked,
+// which they also get when loader calls runtime.Goexit.
+func (c *BigCache) GetOrLoad(key string, loader func() ([]byte, error)) ([]byte, error) {
+	data, err := c.Get(key)
+	if !errors.Is(err, ErrEntryNotFound) || errors.Is(err, ErrEntryNegativeCached) {
+		return data, err
+	}
+	return c.loads.do(key, func() ([]byte, error) {
+		// somebody may have loaded it while we were waiting
+		if data, err := c.Get(key); !errors.Is(err, ErrEntryNotFound) || errors.Is(err, ErrEntryNegativeCached) {
+			return data, err
+		}
+		data, err := loader()
+		if err != nil {
+			if c.config.NegativeTTL > 0 && errors.Is(err, ErrEntryNotFound) {
+				_ = c.SetMiss(key)
+			}
+			return nil, err
+		}
+		return data, c.Set(key, data)
+	})
 }
 
-// Set saves entry under the key.
+// Set saves entry under the key. Nil entry is returned by Get as nil, while empty one as empty slice.
+// Empty key is permitted, but like already hashed keys it is not verified against keys colliding with it.
 func (c *BigCache) Set(key string, entry []byte) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	return c.set(key, c.hash.Sum64(key), entry)
+}
+
+// set is Set for already validated key with its hash.
+func (c *BigCache) set(key string, hashedKey uint64, entry []byte) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("set", key, hashedKey, shard.set([]byte(key), hashedKey, entry))
+}
+
+// SetBytes is Set for key kept in byte slice, which saves conversion of the key to string when Hasher implements
+// BytesHasher. The key is copied, so it could be reused when SetBytes returns.
+func (c *BigCache) SetBytes(key []byte, entry []byte) error {
+	if c.validator != nil {
+		if err := c.validator(string(key)); err != nil {
+			return err
+		}
+	}
+	hashedKey := c.sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	if err := shard.set(key, hashedKey, entry); err != nil {
+		return c.opError("set", string(key), hashedKey, err)
+	}
+	return nil
+}
+
+// SetWithTag saves entry under the key marking it with tag, which is kept in entry header and could be read back
+// by GetWithInfo or from CacheEntry.Tag. Entries stored by Set have tag 0, Append and Prepend keep existing tag.
+// Tags are only kept with full entry header profile, selected by bigcache_full_header build tag, by default
+// ErrHeaderField is returned for tags other than 0.
+func (c *BigCache) SetWithTag(key string, entry []byte, tag uint16) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	if tag != 0 && !headerHasTag {
+		return fmt.Errorf("%w: tag is not kept with %s profile", ErrHeaderField, headerProfile)
+	}
 	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.set(key, hashedKey, entry)
+	return c.opError("set", key, hashedKey, shard.setWithTag([]byte(key), hashedKey, entry, tag))
+}
+
+// SetWithProcessing saves entry of given size under the key letting f to write data directly into reserved cache
+// memory, which saves intermediate allocation and copy for serializers capable of encoding into provided buffer.
+// If f returns error it is returned and previous entry for the key is kept.
+// NOTE: dst initially contains garbage - f must fill all of it. f is called while shard lock is held, dst must
+// not be retained and cache must not be accessed from f.
+func (c *BigCache) SetWithProcessing(key string, size int, f func(dst []byte) error) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	hashedKey := c.hash.Sum64(key)
+	var ferr error
+	shard := c.getShard(hashedKey)
+	err := shard.setWithProcessing([]byte(key), hashedKey, size, func(dst []byte) error {
+		ferr = f(dst)
+		return ferr
+	})
+	if err != nil && err == ferr {
+		// error returned by f is passed as is
+		return err
+	}
+	return c.opError("set", key, hashedKey, err)
+}
+
+// SetMiss marks key as known to be absent replacing any value stored under it. Until Config.NegativeTTL passes
+// Get() returns ErrEntryNegativeCached for this key, so repeated lookups do not have to reach backing store.
+func (c *BigCache) SetMiss(key string) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("set miss", key, hashedKey, shard.setMiss([]byte(key), hashedKey))
 }
 
 // SetHashed saves entry under the key.
 // NOTE: it expects already hashed key.
 func (c *BigCache) SetHashed(hashedKey uint64, entry []byte) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.set(usingAlreadyHashedKey, hashedKey, entry)
+	return c.opError("set", usingAlreadyHashedKey, hashedKey, shard.set(nil, hashedKey, entry))
 }
 
 // Append appends entry under the key if key exists, otherwise
 // it will set the key (same behaviour as Set()). With Append() you can
 // concatenate multiple entries under the same key in an lock optimized way.
 func (c *BigCache) Append(key string, entry []byte) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("append", key, hashedKey, shard.append([]byte(key), hashedKey, entry))
+}
+
+// AppendMulti appends all entries under the key one after another, creating it if key does not exist. Unlike
+// calling Append for every entry, shard is locked once and data is stored once, so it is much cheaper for
+// accumulating many chunks.
+func (c *BigCache) AppendMulti(key string, entries [][]byte) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("append", key, hashedKey, shard.append([]byte(key), hashedKey, entries...))
+}
+
+// Update atomically replaces entry for the key with the one computed by f: under single shard lock f gets current
+// value (found is false when there is none) and returns new one, or keep set to false to remove the entry.
+// This avoids races between separate Get and Set calls. Entry keeps its tag.
+// NOTE: like Processor f is called while shard lock is held and old references shard's buffer (not a copy!), so f
+// must not retain it and must not call cache methods. Returned slice may reference old.
+func (c *BigCache) Update(key string, f func(old []byte, found bool) (new []byte, keep bool)) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
 	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.append(key, hashedKey, entry)
+	return c.opError("update", key, hashedKey, shard.update([]byte(key), hashedKey, func(old []byte, found bool) ([]byte, bool, error) {
+		data, keep := f(old, found)
+		return data, keep, nil
+	}))
 }
 
 // AppendHashed appends entry under the key if key exists, otherwise
@@ -151,34 +763,174 @@ func (c *BigCache) Append(key string, entry []byte) error {
 // concatenate multiple entries under the same key in an lock optimized way.
 // NOTE: it expects already hashed key.
 func (c *BigCache) AppendHashed(hashedKey uint64, entry []byte) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.append(usingAlreadyHashedKey, hashedKey, entry)
+	return c.opError("append", usingAlreadyHashedKey, hashedKey, shard.append(nil, hashedKey, entry))
+}
+
+// Prepend inserts entry before the data stored under the key if key exists, otherwise
+// it will set the key (same behaviour as Set()).
+func (c *BigCache) Prepend(key string, entry []byte) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("prepend", key, hashedKey, shard.prepend([]byte(key), hashedKey, entry))
 }
 
 // Delete removes the key.
 func (c *BigCache) Delete(key string) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
 	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.del(hashedKey)
+	return notFoundError(key, shard.del([]byte(key), hashedKey))
+}
+
+// DeleteBytes is Delete for key kept in byte slice, which saves conversion of the key to string when Hasher
+// implements BytesHasher.
+func (c *BigCache) DeleteBytes(key []byte) error {
+	if c.validator != nil {
+		if err := c.validator(string(key)); err != nil {
+			return err
+		}
+	}
+	hashedKey := c.sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	if err := shard.del(key, hashedKey); err != nil {
+		return notFoundError(string(key), err)
+	}
+	return nil
 }
 
 // DeleteHashed removes the key.
 // NOTE: it expects already hashed key.
 func (c *BigCache) DeleteHashed(hashedKey uint64) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	shard := c.getShard(hashedKey)
-	return shard.del(hashedKey)
+	return notFoundError(usingAlreadyHashedKey, shard.del(nil, hashedKey))
+}
+
+// DeleteFunc removes every entry for which pred returns true, returning number of entries removed.
+// Like in Range pred is called with copies of key and data without holding shard lock, entry is removed only
+// if it was not changed in the meantime. Nothing is removed from frozen cache.
+func (c *BigCache) DeleteFunc(pred func(key string, data []byte) bool) int {
+	if c.isFrozen() {
+		return 0
+	}
+
+	var deleted int
+	for _, shard := range c.getShards() {
+		for _, ref := range shard.copyRefs() {
+			entry, err := shard.getEntry(ref, duplicate)
+			if err != nil || !pred(string(entry.Key), entry.Data) {
+				continue
+			}
+			c.layout.RLock()
+			if !c.isFrozen() && shard.delIfUnchanged(entry.Hash, ref) {
+				deleted++
+			}
+			c.layout.RUnlock()
+		}
+	}
+	return deleted
 }
 
 // Reset empties all cache shards.
 func (c *BigCache) Reset() error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
 	for _, shard := range c.shards {
-		shard.reset(c.config)
+		shard.reset(c.shardConfig())
+	}
+	return nil
+}
+
+// ResetShard empties single shard leaving others intact, see ShardIndex.
+func (c *BigCache) ResetShard(i int) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
 	}
+	if i < 0 || i >= len(c.shards) {
+		return ErrInvalidShardIndex
+	}
+	c.shards[i].reset(c.shardConfig())
 	return nil
 }
 
-// Len computes number of entries in cache.
+// ShardIndex returns index of the shard keeping entry for the key.
+// NOTE: index changes when cache is resized.
+func (c *BigCache) ShardIndex(key string) int {
+	return c.currentShardIndex(c.hash.Sum64(key))
+}
+
+// ShardGeometry describes layout of shard's queue, which is a ring buffer of entries. Entries occupy bytes from Head
+// up to Tail, or when queue has wrapped from Head up to Right and from the beginning of the buffer up to Tail.
+// Queue is reallocated when there is no room for new entry after Tail (and before Head), so comparing Tail and
+// Right with Capacity shows how close the shard is to reallocation.
+type ShardGeometry struct {
+	Head     int // offset of the oldest entry
+	Tail     int // offset new entry is written at
+	Right    int // end of entries written before queue wrapped
+	Count    int // number of entries in the queue, including deleted ones which space was not reclaimed yet
+	Capacity int // size of the queue buffer in bytes
+}
+
+// ShardGeometry returns current layout of shard's queue, see ShardIndex. It is meant for diagnostics and monitoring of
+// fragmentation, values change with every write.
+func (c *BigCache) ShardGeometry(i int) (ShardGeometry, error) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if i < 0 || i >= len(c.shards) {
+		return ShardGeometry{}, ErrInvalidShardIndex
+	}
+	return c.shards[i].geometry(), nil
+}
+
+// Len computes number of entries in cache. Markers stored by SetMiss are not counted, although they take space
+// and count against GlobalMaxEntries like entries do.
 func (c *BigCache) Len() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	var len int
 	for _, shard := range c.shards {
 		len += shard.len()
@@ -188,6 +940,9 @@ func (c *BigCache) Len() int {
 
 // Capacity returns amount of bytes store in the cache.
 func (c *BigCache) Capacity() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
 	var len int
 	for _, shard := range c.shards {
 		len += shard.cap()
@@ -195,20 +950,70 @@ func (c *BigCache) Capacity() int {
 	return len
 }
 
+// OverwrittenBytes returns number of bytes taken by entries replaced by Set and similar methods for existing keys.
+// Entries are stored in queues, so new value is always written at the tail while old one keeps its space until
+// it reaches the head of the queue and is reclaimed. That is why Capacity grows even when the same keys are set
+// over and over again and number of entries does not change.
+func (c *BigCache) OverwrittenBytes() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	var overwritten int
+	for _, shard := range c.shards {
+		overwritten += shard.overwrittenBytes()
+	}
+	return overwritten
+}
+
+// IndexMemory returns estimate of number of bytes taken by shard indexes, which Capacity does not account for.
+// For many small entries it is a significant part of cache memory. Estimate assumes that indexes are only as big as
+// current number of entries requires, while Go maps do not shrink after entries are removed.
+func (c *BigCache) IndexMemory() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	var memory int
+	for _, shard := range c.shards {
+		memory += shard.indexMemory()
+	}
+	return memory
+}
+
+// Headroom returns number of bytes which could be written into the cache before shards have to reallocate their
+// memory or, when they reached HardMaxCacheSize, evict entries. It includes space taken by entry headers and keys,
+// and is summed across shards, so it is only an estimate for writes of entries with unknown keys.
+func (c *BigCache) Headroom() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	var headroom int
+	for _, shard := range c.shards {
+		headroom += shard.headroom()
+	}
+	return headroom
+}
+
 // Stats returns cache's statistics.
 func (c *BigCache) Stats() Stats {
 	var s Stats
+	c.StatsInto(&s)
+	return s
+}
+
+// StatsInto fills dst with cache's statistics, so frequent scrapes could reuse the same struct.
+// It does not allocate memory.
+func (c *BigCache) StatsInto(dst *Stats) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	*dst = c.resized
 	for _, shard := range c.shards {
-		tmp := shard.getStats()
-		s.Hits += tmp.Hits
-		s.Misses += tmp.Misses
-		s.DelHits += tmp.DelHits
-		s.DelMisses += tmp.DelMisses
-		s.Collisions += tmp.Collisions
-		s.EvictedExpired += tmp.EvictedExpired
-		s.EvictedNoSpace += tmp.EvictedNoSpace
+		dst.add(shard.getStats())
+		if shard.filter != nil {
+			dst.BloomFillRatio += shard.filter.fillRatio()
+		}
 	}
-	return s
+	dst.BloomFillRatio /= float64(len(c.shards))
 }
 
 // Range attempts to call f sequentially for each key and value present in the cache.
@@ -224,18 +1029,43 @@ func (c *BigCache) Stats() Stats {
 //
 // Range is replacement for over-complicated EntryInfoIterator.
 func (c *BigCache) Range(f Processor) error {
+	return c.rangeCopies(f, (*cacheShard).copyRefs, duplicate)
+}
 
-	// make sure entry is safe to use while shard is unlocked
-	duplicator := func(ce *CacheEntry) error {
-		ce.Key = ce.CopyKeyData()
-		ce.Data = ce.CopyData(0)
+// RangeOrdered is Range which visits entries of every shard in order they were stored, from the oldest to the newest.
+// NOTE: order is kept within a shard only, shards are visited one after another, so entries are not globally ordered.
+func (c *BigCache) RangeOrdered(f Processor) error {
+	return c.rangeCopies(f, (*cacheShard).orderedRefs, duplicate)
+}
+
+// rangeBuffers keeps buffers entries are copied into by RangePooled.
+var rangeBuffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// RangePooled is Range which copies entries into a buffer taken from a pool and reused for every entry rather than
+// allocating new key and data slices, so iterating over large cache produces no garbage.
+// NOTE: entry given to f is only valid until f returns, f must not retain its key and data slices.
+func (c *BigCache) RangePooled(f Processor) error {
+	buf := rangeBuffers.Get().(*[]byte)
+	defer rangeBuffers.Put(buf)
+
+	return c.rangeCopies(f, (*cacheShard).copyRefs, func(ce *CacheEntry) error {
+		kl := len(ce.Key)
+		*buf = append(append((*buf)[:0], ce.Key...), ce.Data...)
+		ce.Key = (*buf)[:kl:kl]
+		if ce.Data != nil {
+			ce.Data = (*buf)[kl:]
+		}
 		return nil
-	}
+	})
+}
 
-	for _, shard := range c.shards {
+// rangeCopies calls f for copies of entries referenced by snapshot taken by refs from every shard, entries are
+// copied by copier while shard lock is held.
+func (c *BigCache) rangeCopies(f Processor, refs func(*cacheShard) []qref, copier Processor) error {
+	for _, shard := range c.getShards() {
 		// taking snapshot of shard indices
-		for _, ref := range shard.copyRefs() {
-			if entry, err := shard.getEntry(ref, duplicator); err != nil {
+		for _, ref := range refs(shard) {
+			if entry, err := shard.getEntry(ref, copier); err != nil {
 				if !errors.Is(err, ErrEntryNotFound) {
 					return err
 				}
@@ -252,12 +1082,227 @@ func (c *BigCache) Range(f Processor) error {
 	return nil
 }
 
+// duplicate makes sure entry is safe to use while shard is unlocked.
+func duplicate(ce *CacheEntry) error {
+	ce.Key = ce.CopyKeyData()
+	if ce.Data != nil {
+		ce.Data = ce.CopyData(0)
+	}
+	return nil
+}
+
+// RangeInfo is Range which also gives f information about every entry, i.e. its age and time left until it
+// expires, which is useful for inspection of cache contents. Like in Range f gets copies of entries.
+func (c *BigCache) RangeInfo(f func(ce *CacheEntry, info EntryInfo) error) error {
+	c.layout.RLock()
+	lifeWindow := uint64(c.config.LifeWindow.Seconds())
+	c.layout.RUnlock()
+
+	return c.Range(func(ce *CacheEntry) error {
+		return f(ce, newEntryInfo(ce.TS, ce.Tag, c.clock.epoch(), lifeWindow))
+	})
+}
+
+// RangeUnsafe is Range which avoids copying entries: f is called while shard read lock is held and, like
+// Processor passed to WithProcessing functions, gets entry referencing underlying shard buffer (not a copy!).
+// It is much faster than Range for read-only aggregation, i.e. counting or summing.
+// NOTE: f must not retain key and data slices or modify them, and must not call cache methods - writes to the shard
+// are blocked until f returns and calling cache from f may deadlock.
+func (c *BigCache) RangeUnsafe(f Processor) error {
+	for _, shard := range c.getShards() {
+		if err := shard.rangeLocked(f); err != nil {
+			if errors.Is(err, ErrEntryNotFound) {
+				// stop is requested
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOldest returns copy of the entry with the smallest timestamp, which is the next to expire or to be evicted.
+// It returns an ErrEntryNotFound when cache is empty.
+func (c *BigCache) GetOldest() (*CacheEntry, error) {
+	return c.edgeEntry(false)
+}
+
+// GetNewest returns copy of the entry with the largest timestamp, i.e. the one stored most recently.
+// It returns an ErrEntryNotFound when cache is empty.
+// NOTE: it has to walk through all entries in the cache.
+func (c *BigCache) GetNewest() (*CacheEntry, error) {
+	return c.edgeEntry(true)
+}
+
+func (c *BigCache) edgeEntry(newest bool) (*CacheEntry, error) {
+	var edge *CacheEntry
+	for _, shard := range c.getShards() {
+		ce := shard.edgeEntry(newest)
+		if ce == nil {
+			continue
+		}
+		if edge == nil || (newest && ce.TS > edge.TS) || (!newest && ce.TS < edge.TS) {
+			edge = ce
+		}
+	}
+	if edge == nil {
+		return nil, ErrEntryNotFound
+	}
+	return edge, nil
+}
+
+// SizeHistogram returns distribution of data sizes of entries in the cache. Buckets are powers of two: bucket 0 counts
+// empty entries and bucket i > 0 counts entries with size in [2^(i-1), 2^i) range, i.e. bucket 11 counts entries of
+// 1KB up to 2KB. Slice ends with the last non-empty bucket.
+func (c *BigCache) SizeHistogram() []int {
+	var histogram []int
+	for _, shard := range c.getShards() {
+		histogram = shard.sizeHistogram(histogram)
+	}
+	return histogram
+}
+
+// EntrySize describes size of cached entry, see LargestEntries.
+type EntrySize struct {
+	Key  string // empty for entries stored with already hashed keys
+	Hash uint64
+	Size int // number of bytes entry takes in the cache, see CacheEntry.Size
+}
+
+// entrySizes is a min-heap of entries, so the smallest of the largest entries found so far is replaced first.
+type entrySizes []EntrySize
+
+func (h entrySizes) Len() int            { return len(h) }
+func (h entrySizes) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h entrySizes) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *entrySizes) Push(x interface{}) { *h = append(*h, x.(EntrySize)) }
+func (h *entrySizes) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// LargestEntries returns up to n largest entries in the cache, the largest first. It is a diagnostic helping to
+// find unexpectedly big values, memory it needs is proportional to n rather than to number of entries.
+func (c *BigCache) LargestEntries(n int) []EntrySize {
+	if n <= 0 {
+		return nil
+	}
+	largest := make(entrySizes, 0, n)
+	for _, shard := range c.getShards() {
+		shard.largestEntries(&largest, n)
+	}
+	sort.Sort(sort.Reverse(largest))
+	return largest
+}
+
+// ExpiringWithin returns number of entries which expire (LifeWindow passes since they were stored) within d from now,
+// including expired entries not removed by cleanup yet.
+func (c *BigCache) ExpiringWithin(d time.Duration) int {
+	if d < 0 {
+		d = 0
+	}
+	deadline := c.clock.epoch() + uint64(d/time.Second)
+
+	var count int
+	for _, shard := range c.getShards() {
+		count += shard.expiringBefore(deadline)
+	}
+	return count
+}
+
+// Subscribe returns channel delivering events for every successful Set, Delete and eviction along with
+// function to cancel subscription. Events are published while shard lock is held, so they are never allowed
+// to block cache operations: when subscriber falls behind and its buffer (see Config.EventBufferSize) is full
+// event is dropped and counted in DroppedEvents().
+// Channel is closed when subscription is canceled or cache is closed, events buffered before that could still be
+// received, so consumer ranging over it processes every delivered event and knows no more are coming.
+func (c *BigCache) Subscribe() (<-chan CacheEvent, func()) {
+	return c.events.subscribe()
+}
+
+// DroppedEvents returns number of events which were not delivered because subscriber's buffer was full.
+func (c *BigCache) DroppedEvents() int64 {
+	return c.events.droppedEvents()
+}
+
 func (c *BigCache) cleanUp(currentTimestamp uint64) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return
+	}
+
 	for _, shard := range c.shards {
 		shard.cleanUp(currentTimestamp)
 	}
 }
 
+// shardConfig returns configuration of shards in current layout, which differs from the initial one by number of
+// shards after Resize.
+// NOTE: layout lock must be held.
+func (c *BigCache) shardConfig() Config {
+	config := c.config
+	config.Shards = len(c.shards)
+	return config
+}
+
+// getShards returns current shards, which could be used without holding layout lock.
+// NOTE: after Resize returned shards are not part of the cache anymore, but keep data they had at the moment unless
+// Config.Storage is set, their memory is released then.
+func (c *BigCache) getShards() []*cacheShard {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	return c.shards
+}
+
+// NOTE: layout lock must be held while shard is in use.
 func (c *BigCache) getShard(hashedKey uint64) (shard *cacheShard) {
-	return c.shards[hashedKey&c.shardMask]
+	return c.shards[c.shardIndex(hashedKey)]
+}
+
+// sum64 hashes key kept in byte slice.
+func (c *BigCache) sum64(key []byte) uint64 {
+	if h, ok := c.hash.(BytesHasher); ok {
+		return h.Sum64Bytes(key)
+	}
+	return c.hash.Sum64(string(key))
+}
+
+// validate checks key using Config.KeyValidator.
+func (c *BigCache) validate(key string) error {
+	if c.validator == nil {
+		return nil
+	}
+	return c.validator(key)
+}
+
+// maxKeyInError is a length of key prefix kept in error messages.
+const maxKeyInError = 64
+
+// opError adds key (or hash for already hashed keys) and shard index to error returned by shard operation, so it is
+// clear which operation failed. Wrapped error is still detectable with errors.Is.
+// NOTE: layout lock must be held.
+func (c *BigCache) opError(op, key string, hashedKey uint64, err error) error {
+	if err == nil {
+		return nil
+	}
+	if key == usingAlreadyHashedKey {
+		return fmt.Errorf("%s hash %#x in shard %d: %w", op, hashedKey, c.shardIndex(hashedKey), err)
+	}
+	if len(key) > maxKeyInError {
+		key = key[:maxKeyInError] + "..."
+	}
+	return fmt.Errorf("%s key %q in shard %d: %w", op, key, c.shardIndex(hashedKey), err)
+}
+
+func (c *BigCache) shardIndex(hashedKey uint64) int {
+	if c.selector == nil {
+		// default, keep it fast
+		return int(hashedKey & c.shardMask)
+	}
+	return c.selector.Shard(hashedKey, len(c.shards))
 }
@@ -89,6 +89,69 @@ func BenchmarkIterateOverCache(b *testing.B) {
 	}
 }
 
+func BenchmarkRangeMillionEntries(b *testing.B) {
+	cache, _ := NewBigCache(Config{
+		Shards:             256,
+		LifeWindow:         1000 * time.Second,
+		MaxEntriesInWindow: 1000000,
+		MaxEntrySize:       100,
+	})
+	m := blob('a', 64)
+	for i := 0; i < 1000000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), m)
+	}
+	nop := func(*CacheEntry) error { return nil }
+
+	b.Run("Range", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cache.Range(nop)
+		}
+	})
+	b.Run("RangePooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cache.RangePooled(nop)
+		}
+	})
+}
+
+// BenchmarkReadMostlyMix measures throughput of 99% reads and 1% writes, which shows how writers locking shards
+// delay readers depending on number of shards and read lock stripes.
+func BenchmarkReadMostlyMix(b *testing.B) {
+	for _, shards := range []int{1, 16, 256, 1024} {
+		for _, stripes := range []int{0, 8} {
+			b.Run(fmt.Sprintf("%d-shards-%d-stripes", shards, stripes), func(b *testing.B) {
+				cache, _ := NewBigCache(Config{
+					Shards:             shards,
+					ReadLockStripes:    stripes,
+					LifeWindow:         1000 * time.Second,
+					MaxEntriesInWindow: 10000,
+					MaxEntrySize:       500,
+				})
+				keys := make([]string, 10000)
+				for i := range keys {
+					keys[i] = fmt.Sprintf("key-%d", i)
+					cache.Set(keys[i], message)
+				}
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rnd := rand.New(rand.NewSource(rand.Int63()))
+					for pb.Next() {
+						key := keys[rnd.Intn(len(keys))]
+						if rnd.Intn(100) == 0 {
+							cache.Set(key, message)
+						} else {
+							cache.Get(key)
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
 func BenchmarkWriteToCacheWith1024ShardsAndSmallShardInitSize(b *testing.B) {
 	writeToCache(b, 1024, 100*time.Second, 100)
 }
@@ -159,3 +222,66 @@ func readFromCacheNonExistentKeys(b *testing.B, shards int) {
 		}
 	})
 }
+
+func BenchmarkReadFromCacheWithStats(b *testing.B) {
+	for _, disabled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("disabled-%t", disabled), func(b *testing.B) {
+			cache, _ := NewBigCache(Config{
+				Shards:             1,
+				LifeWindow:         1000 * time.Second,
+				MaxEntriesInWindow: 100,
+				MaxEntrySize:       500,
+				DisableStats:       disabled,
+			})
+			cache.Set("key", message)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				b.ReportAllocs()
+				dst := make([]byte, len(message))
+				for pb.Next() {
+					cache.GetInto("key", dst)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadFromCacheInto(b *testing.B) {
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Minute))
+	cache.Set("key", message)
+	dst := make([]byte, len(message))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetInto("key", dst)
+	}
+}
+
+func BenchmarkEvictionPolicyHitRatio(b *testing.B) {
+	for _, policy := range []EvictionPolicy{FIFO, SLRU} {
+		b.Run(fmt.Sprintf("policy-%d", policy), func(b *testing.B) {
+			cache, _ := NewBigCache(Config{
+				Shards:             1,
+				LifeWindow:         1000 * time.Second,
+				MaxEntriesInWindow: 100,
+				MaxEntrySize:       500,
+				HardMaxCacheSize:   1,
+				EvictionPolicy:     policy,
+			})
+			rnd := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// small hot set read over and over interleaved with scan of keys never read again
+				key := fmt.Sprintf("hot-%d", rnd.Intn(100))
+				if _, err := cache.Get(key); err != nil {
+					cache.Set(key, message)
+				}
+				cache.Set(fmt.Sprintf("scan-%d", i), message)
+			}
+			b.ReportMetric(cache.Stats().HitRatio(), "hit-ratio")
+		})
+	}
+}
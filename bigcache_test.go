@@ -2,12 +2,16 @@ package bigcache
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -45,7 +49,7 @@ func TestAppendAndGetOnCache(t *testing.T) {
 	_, err := cache.Get(key)
 
 	// then
-	assertEqual(t, ErrEntryNotFound, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 
 	// when
 	cache.Append(key, value1)
@@ -77,6 +81,32 @@ func TestAppendAndGetOnCache(t *testing.T) {
 	assertEqual(t, expectedValue, cachedValue)
 }
 
+func TestPrependAndGetOnCache(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	key := "key"
+
+	// when
+	err := cache.Prepend(key, []byte("3"))
+	cachedValue, err1 := cache.Get(key)
+
+	// then
+	noError(t, err)
+	noError(t, err1)
+	assertEqual(t, []byte("3"), cachedValue)
+
+	// when
+	cache.Prepend(key, []byte("2"))
+	cache.Prepend(key, []byte("1"))
+	cachedValue, err = cache.Get(key)
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("123"), cachedValue)
+}
+
 // TestAppendRandomly does simultaneous appends to check for corruption errors.
 func TestAppendRandomly(t *testing.T) {
 	t.Parallel()
@@ -184,7 +214,7 @@ func TestEntryNotFound(t *testing.T) {
 	_, err := cache.Get("nonExistingKey")
 
 	// then
-	assertEqual(t, ErrEntryNotFound, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 }
 
 func TestTimingEviction(t *testing.T) {
@@ -206,7 +236,7 @@ func TestTimingEviction(t *testing.T) {
 	_, err := cache.Get("key")
 
 	// then
-	assertEqual(t, ErrEntryNotFound, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 }
 
 func TestTimingEvictionShouldEvictOnlyFromUpdatedShard(t *testing.T) {
@@ -250,10 +280,48 @@ func TestCleanShouldEvictAll(t *testing.T) {
 	value, err := cache.Get("key")
 
 	// then
-	assertEqual(t, ErrEntryNotFound, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	assertEqual(t, value, []byte(nil))
 }
 
+func TestCleanupInBatches(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	expired := 0
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		CleanupBatchSize:   3,
+		OnExpired:          func(ce *CacheEntry) { expired++ },
+	}, clock)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("old%d", i), []byte("value"))
+	}
+	clock.set(5)
+	cache.Set("fresh1", []byte("value"))
+	cache.Set("fresh2", []byte("value"))
+	before := expired // every set removes the oldest entry when it is expired
+
+	// when
+	done := cache.shards[0].expireOldest(clock.epoch())
+
+	// then
+	assertEqual(t, false, done)
+	assertEqual(t, before+3, expired)
+
+	// when
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, 10, expired)
+	assertEqual(t, 2, cache.Len())
+	assertEqual(t, int64(10), cache.Stats().EvictedExpired)
+}
+
 func TestOnRemoveCallback(t *testing.T) {
 	t.Parallel()
 
@@ -282,6 +350,122 @@ func TestOnRemoveCallback(t *testing.T) {
 	assertEqual(t, true, onRemoveInvoked)
 }
 
+func TestOnBeforeEvict(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	var asked []string
+	var expired []string
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		OnBeforeEvict: func(ce *CacheEntry, reason RemoveReason) bool {
+			assertEqual(t, Expired, reason)
+			asked = append(asked, string(ce.Key))
+			return string(ce.Key) == "kept"
+		},
+		OnExpired: func(ce *CacheEntry) { expired = append(expired, string(ce.Key)) },
+	}, clock)
+	cache.Set("kept", []byte("value"))
+	cache.Set("evicted", []byte("value"))
+
+	// when
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, []string{"kept", "evicted"}, asked)
+	assertEqual(t, []string{"evicted"}, expired)
+	value, err := cache.Get("kept")
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	_, err = cache.Get("evicted")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, true, cache.Verify() == nil)
+
+	// when - kept entry got a fresh timestamp, so it is not expired yet
+	clock.set(5 + 1)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, 2, len(asked))
+}
+
+func TestOnBeforeEvictCannotKeepEntriesWithoutSpace(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var asked []RemoveReason
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       32,
+		HardMaxCacheSize:   1,
+		OnBeforeEvict: func(ce *CacheEntry, reason RemoveReason) bool {
+			asked = append(asked, reason)
+			return true
+		},
+	})
+	value := blob('a', 1024*300)
+
+	// when
+	noError(t, cache.Set("a", value))
+	noError(t, cache.Set("b", value))
+	noError(t, cache.Set("c", value))
+	noError(t, cache.Set("d", value))
+
+	// then
+	assertEqual(t, true, len(asked) > 0)
+	for _, reason := range asked {
+		assertEqual(t, NoSpace, reason)
+	}
+	_, err := cache.Get("a")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	_, err = cache.Get("d")
+	noError(t, err)
+}
+
+func TestOnShardFull(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var full []string
+	var shards []int
+	cache, _ := NewBigCache(Config{
+		Shards:             2,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		OnShardFull: func(ce *CacheEntry, shard int) {
+			full = append(full, string(ce.Key))
+			shards = append(shards, shard)
+		},
+	})
+	value := blob('a', 1024*1024)
+
+	// when
+	errSmall := cache.Set("small", []byte("value"))
+	errBig := cache.Set("big", value)
+	errProcessed := cache.SetWithProcessing("processed", len(value), func(dst []byte) error {
+		copy(dst, value)
+		return nil
+	})
+
+	// then
+	noError(t, errSmall)
+	assertEqual(t, true, errors.Is(errBig, ErrQueueEntryTooBig))
+	assertEqual(t, true, errors.Is(errProcessed, ErrQueueEntryTooBig))
+	assertEqual(t, []string{"big", "processed"}, full)
+	assertEqual(t, []int{cache.ShardIndex("big"), cache.ShardIndex("processed")}, shards)
+}
+
 func TestOnRemoveReasons(t *testing.T) {
 	// t.Parallel()
 
@@ -321,7 +505,7 @@ func TestOnRemoveReasons(t *testing.T) {
 	err = cache.Delete("key")
 
 	// then
-	assertEqual(t, err, ErrEntryNotFound)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	assertEqual(t, false, onRemoveDeleted)
 	assertEqual(t, true, onRemoveExpired)
 
@@ -341,6 +525,48 @@ func TestOnRemoveReasons(t *testing.T) {
 	assertEqual(t, false, onRemoveExpired)
 }
 
+func TestOnRemoveReasonCallbacks(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	var calls []string
+	record := func(name string) func(*CacheEntry) {
+		return func(ce *CacheEntry) {
+			calls = append(calls, name+":"+string(ce.Key))
+		}
+	}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		OnRemove: func(ce *CacheEntry, reason RemoveReason) {
+			calls = append(calls, "remove:"+string(ce.Key))
+		},
+		OnExpired: record("expired"),
+		OnEvicted: record("evicted"),
+		OnDeleted: record("deleted"),
+	}, &clock)
+
+	// when
+	cache.Set("expiring", []byte("value"))
+	cache.Set("deleted", []byte("value"))
+	cache.Delete("deleted")
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+	cache.Set("big", blob('a', 600*1024))
+	cache.Set("bigger", blob('a', 600*1024))
+
+	// then
+	assertEqual(t, []string{
+		"remove:deleted", "deleted:deleted",
+		"remove:expiring", "expired:expiring",
+		"remove:big", "evicted:big",
+	}, calls)
+}
+
 func TestCacheLen(t *testing.T) {
 	t.Parallel()
 
@@ -461,7 +687,7 @@ func TestCacheStats(t *testing.T) {
 	}
 	for i := 100; i < 110; i++ {
 		_, err := cache.Get(fmt.Sprintf("key%d", i))
-		assertEqual(t, ErrEntryNotFound, err)
+		assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	}
 	for i := 10; i < 20; i++ {
 		err := cache.Delete(fmt.Sprintf("key%d", i))
@@ -469,7 +695,7 @@ func TestCacheStats(t *testing.T) {
 	}
 	for i := 110; i < 120; i++ {
 		err := cache.Delete(fmt.Sprintf("key%d", i))
-		assertEqual(t, ErrEntryNotFound, err)
+		assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	}
 
 	// then
@@ -490,7 +716,7 @@ func TestCacheDel(t *testing.T) {
 	err := cache.Delete("nonExistingKey")
 
 	// then
-	assertEqual(t, err, ErrEntryNotFound)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 
 	// and when
 	cache.Set("existingKey", nil)
@@ -625,6 +851,88 @@ func TestIterateOnResetCache(t *testing.T) {
 	assertEqual(t, 0, count)
 }
 
+func TestRangeConcurrentWithReset(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	fill := func(prefix string, n int) {
+		for i := 0; i < n; i++ {
+			key := fmt.Sprintf("%s-key%d", prefix, i)
+			cache.Set(key, []byte(key+strings.Repeat("-", i%32)))
+		}
+	}
+	fill("initial", 1000)
+
+	// when
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			cache.Reset()
+			fill(fmt.Sprintf("round%d", i), 100+i*10)
+		}
+	}()
+	var errs []error
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		err := cache.Range(func(ce *CacheEntry) error {
+			if !bytes.HasPrefix(ce.Data, ce.Key) {
+				return fmt.Errorf("entry %q has data %q", ce.Key, ce.Data)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// then
+	assertEqual(t, 0, len(errs))
+}
+
+func TestRangeSkipsReferencesInvalidatedByReset(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	shard := cache.shards[0]
+	refs := shard.copyRefs()
+
+	// when
+	cache.Reset()
+	cache.Set("longer-key-shifting-entries", []byte("other value"))
+
+	// then - reference to the start of the new entry reads it, others are skipped
+	var found []string
+	for _, ref := range refs {
+		entry, err := shard.getEntry(ref, duplicate)
+		if err == nil {
+			found = append(found, entry.CopyKey())
+			continue
+		}
+		assertEqual(t, ErrEntryNotFound, err)
+	}
+	assertEqual(t, []string{"longer-key-shifting-entries"}, found)
+}
+
 func TestGetOnResetCache(t *testing.T) {
 	t.Parallel()
 
@@ -647,7 +955,7 @@ func TestGetOnResetCache(t *testing.T) {
 	// then
 	value, err := cache.Get("key1")
 
-	assertEqual(t, err, ErrEntryNotFound)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	assertEqual(t, value, []byte(nil))
 }
 
@@ -697,8 +1005,8 @@ func TestOldestEntryDeletionWhenMaxCacheSizeIsReached(t *testing.T) {
 	entry3, _ := cache.Get("key3")
 
 	// then
-	assertEqual(t, key1Err, ErrEntryNotFound)
-	assertEqual(t, key2Err, ErrEntryNotFound)
+	assertEqual(t, true, errors.Is(key1Err, ErrEntryNotFound))
+	assertEqual(t, true, errors.Is(key2Err, ErrEntryNotFound))
 	assertEqual(t, blob('c', 1024*800), entry3)
 }
 
@@ -744,7 +1052,77 @@ func TestEntryBiggerThanMaxShardSizeError(t *testing.T) {
 	err := cache.Set("key1", blob('a', 1024*1025))
 
 	// then
-	assertEqual(t, "new entry is bigger than max shard size: byte queue is empty", err.Error())
+	assertEqual(t, `set key "key1" in shard 0: new entry is bigger than max shard size: byte queue cannot expand, entry is too big`, err.Error())
+	assertEqual(t, true, errors.Is(err, ErrQueueEntryTooBig))
+
+	// when
+	err = cache.SetHashed(0x10, blob('a', 1024*1025))
+
+	// then
+	assertEqual(t, "set hash 0x10 in shard 0: new entry is bigger than max shard size: byte queue cannot expand, entry is too big", err.Error())
+}
+
+func TestOversizedEntryDoesNotEvict(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       1,
+		HardMaxCacheSize:   1,
+	})
+	for i := 0; i < 200; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), blob('a', 1024*9))
+	}
+	length, evicted := cache.Len(), cache.Stats().EvictedNoSpace
+
+	// when
+	err := cache.Set("huge", blob('a', 1024*1025))
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrQueueEntryTooBig))
+	assertEqual(t, true, evicted > 0)
+	assertEqual(t, length, cache.Len())
+	assertEqual(t, evicted, cache.Stats().EvictedNoSpace)
+}
+
+func TestMaxEvictionScan(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:           1,
+		LifeWindow:       time.Minute,
+		HardMaxCacheSize: 1,
+		PreallocateToMax: true,
+		MaxEvictionScan:  10,
+	})
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), blob('a', 1000))
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Delete(fmt.Sprintf("key%d", i))
+	}
+
+	// when
+	err := cache.Set("big", blob('b', 100*1024))
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrQueueFull))
+	assertEqual(t, int64(10), cache.Stats().ReclaimedTombstones)
+
+	// when
+	attempts := 1
+	for ; err != nil && attempts < 20; attempts++ {
+		err = cache.Set("big", blob('b', 100*1024))
+	}
+
+	// then
+	noError(t, err)
+	assertEqual(t, true, attempts > 2)
+	assertEqual(t, 1, cache.Len())
 }
 
 func TestHashCollision(t *testing.T) {
@@ -781,13 +1159,78 @@ func TestHashCollision(t *testing.T) {
 	cachedValue, err = cache.Get("liquid")
 
 	// then
-	assertEqual(t, ErrEntryNotFound, err)
-	assertEqual(t, []byte(nil), cachedValue)
+	noError(t, err)
+	assertEqual(t, []byte("value"), cachedValue)
+	assertEqual(t, 2, cache.Len())
 
 	assertEqual(t, "Collision detected. Both %q and %q have the same hash %x", ml.lastFormat)
 	assertEqual(t, cache.Stats().Collisions, int64(1))
 }
 
+func TestCollidingKeysAreChained(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+		BloomFilterSize:    64,
+	})
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	// when
+	cache.Set("key2", []byte("updated"))
+	deleteErr := cache.Delete("key3")
+	_, missingErr := cache.Get("missing")
+
+	// then
+	noError(t, deleteErr)
+	assertEqual(t, true, errors.Is(missingErr, ErrEntryNotFound))
+	assertEqual(t, 4, cache.Len())
+	for key, expected := range map[string]string{"key0": "value0", "key1": "value1", "key2": "updated", "key4": "value4"} {
+		value, err := cache.Get(key)
+		noError(t, err)
+		assertEqual(t, []byte(expected), value)
+	}
+	_, err := cache.Get("key3")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	var keys []string
+	cache.RangeOrdered(func(ce *CacheEntry) error {
+		keys = append(keys, string(ce.Key))
+		return nil
+	})
+	assertEqual(t, []string{"key0", "key1", "key4", "key2"}, keys)
+	noError(t, cache.Verify())
+
+	// when - the first entry stored under the hash goes away
+	evicted := cache.EvictOldest(1)
+	value, err := cache.GetHashed(5)
+
+	// then
+	assertEqual(t, 1, evicted)
+	noError(t, err)
+	assertEqual(t, []byte("value1"), value)
+	assertEqual(t, 3, cache.Len())
+	_, err = cache.Get("key0")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+
+	// when
+	cache.Delete("key1")
+	cache.Delete("key2")
+	cache.Delete("key4")
+
+	// then
+	assertEqual(t, 0, cache.Len())
+	assertEqual(t, 0, len(cache.shards[0].chains))
+	assertEqual(t, false, cache.shards[0].filter.mayContain(5))
+	noError(t, cache.Verify())
+}
+
 func TestNilValueCaching(t *testing.T) {
 	t.Parallel()
 
@@ -814,7 +1257,7 @@ func TestNilValueCaching(t *testing.T) {
 
 	// then
 	noError(t, err)
-	assertEqual(t, []byte{}, cachedValue)
+	assertEqual(t, []byte(nil), cachedValue)
 
 	// when
 	cache.Set("Nietzsche", []byte(nil))
@@ -822,7 +1265,99 @@ func TestNilValueCaching(t *testing.T) {
 
 	// then
 	noError(t, err)
-	assertEqual(t, []byte{}, cachedValue)
+	assertEqual(t, []byte(nil), cachedValue)
+}
+
+func TestEmptyAndNilKeysAndValues(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		key   string
+		value []byte
+	}{
+		{"", nil},
+		{"", []byte{}},
+		{"key", nil},
+		{"key", []byte{}},
+	} {
+		// given
+		cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+
+		// when
+		err := cache.Set(tc.key, tc.value)
+		value, getErr := cache.Get(tc.key)
+		var processed []byte
+		cache.GetWithProcessing(tc.key, func(ce *CacheEntry) error {
+			processed = ce.Data
+			return nil
+		})
+		var ranged []byte
+		cache.Range(func(ce *CacheEntry) error {
+			ranged = ce.Data
+			return nil
+		})
+
+		// then
+		noError(t, err)
+		noError(t, getErr)
+		assertEqual(t, tc.value == nil, value == nil)
+		assertEqual(t, 0, len(value))
+		assertEqual(t, tc.value == nil, processed == nil)
+		assertEqual(t, tc.value == nil, ranged == nil)
+		assertEqual(t, 1, cache.Len())
+	}
+}
+
+func TestNilEmptyAndAbsentValues(t *testing.T) {
+	t.Parallel()
+
+	cipher, _ := NewAESGCMCipher(make([]byte, 16))
+	for _, c := range []Cipher{nil, cipher} {
+		// given
+		config := DefaultConfig(5 * time.Second)
+		config.Shards = 4
+		config.Cipher = c
+		config.NegativeTTL = time.Minute
+		original, _ := NewBigCache(config)
+		original.Set("nil", nil)
+		original.Set("empty", []byte{})
+		original.SetMiss("negative")
+		var buf bytes.Buffer
+		noError(t, original.Save(&buf))
+		loaded, _ := NewBigCache(config)
+		noError(t, loaded.Load(&buf))
+
+		for _, cache := range []*BigCache{original, loaded} {
+			// when
+			nilValue, nilErr := cache.Get("nil")
+			emptyValue, emptyErr := cache.Get("empty")
+			peeked, _ := cache.Peek("empty")
+			_, absentErr := cache.Get("absent")
+			_, negativeErr := cache.Get("negative")
+
+			// then
+			noError(t, nilErr)
+			noError(t, emptyErr)
+			assertEqual(t, []byte(nil), nilValue)
+			assertEqual(t, []byte{}, emptyValue)
+			assertEqual(t, []byte{}, peeked)
+			assertEqual(t, true, errors.Is(absentErr, ErrEntryNotFound))
+			assertEqual(t, true, errors.Is(negativeErr, ErrEntryNegativeCached))
+			assertEqual(t, true, cache.Has("nil"))
+			assertEqual(t, true, cache.Has("empty"))
+			assertEqual(t, false, cache.Has("absent"))
+			assertEqual(t, false, cache.Has("negative"))
+		}
+
+		// when
+		original.Append("nil", []byte{})
+		appended, _ := original.Get("nil")
+		original.Delete("empty")
+
+		// then
+		assertEqual(t, []byte{}, appended)
+		assertEqual(t, false, original.Has("empty"))
+	}
 }
 
 func TestClosing(t *testing.T) {
@@ -862,7 +1397,7 @@ func TestEntryNotPresent(t *testing.T) {
 
 	// when
 	value, err := cache.Get("blah")
-	assertEqual(t, ErrEntryNotFound, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
 	assertEqual(t, cache.Stats().Misses, int64(1))
 	assertEqual(t, []byte(nil), value)
 }
@@ -925,3 +1460,1732 @@ func (mc *mockedClock) set(value uint64) {
 func blob(char byte, len int) []byte {
 	return bytes.Repeat([]byte{char}, len)
 }
+
+func TestHashMatchesKeyedMethods(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	cache.Set("key", []byte("value"))
+
+	// when
+	hashedKey := cache.Hash("key")
+	value, err := cache.GetHashed(hashedKey)
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, cache.ShardIndex("key"), cache.shardIndex(hashedKey))
+
+	// when
+	noError(t, cache.DeleteHashed(hashedKey))
+	_, err = cache.Get("key")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
+
+func TestGetOrComputeHashed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	computed := 0
+	compute := func() ([]byte, error) {
+		computed++
+		return []byte("value"), nil
+	}
+
+	// when
+	missed, missHash, missErr := cache.GetOrComputeHashed("key", compute)
+	hit, hitHash, hitErr := cache.GetOrComputeHashed("key", compute)
+
+	// then
+	noError(t, missErr)
+	noError(t, hitErr)
+	assertEqual(t, 1, computed)
+	assertEqual(t, []byte("value"), missed)
+	assertEqual(t, []byte("value"), hit)
+	assertEqual(t, cache.Hash("key"), missHash)
+	assertEqual(t, missHash, hitHash)
+
+	// then - entry is stored under the key, so keyed and hashed methods agree
+	value, err := cache.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	value, err = cache.GetHashed(missHash)
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+
+	// when
+	noError(t, cache.SetHashed(missHash, []byte("updated")))
+	value, err = cache.GetHashed(missHash)
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("updated"), value)
+}
+
+func TestGetOrComputeHashedDoesNotCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	failure := errors.New("failure")
+
+	// when
+	_, hashedKey, err := cache.GetOrComputeHashed("key", func() ([]byte, error) {
+		return nil, failure
+	})
+
+	// then
+	assertEqual(t, failure, err)
+	assertEqual(t, cache.Hash("key"), hashedKey)
+	assertEqual(t, 0, cache.Len())
+}
+
+func TestGetOrLoadCoalescesConcurrentMisses(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	var calls int32
+	release := make(chan struct{})
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("loaded"), nil
+	}
+
+	// when
+	var wg sync.WaitGroup
+	results := make([][]byte, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.GetOrLoad("key", loader)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// then
+	assertEqual(t, int32(1), atomic.LoadInt32(&calls))
+	for _, r := range results {
+		assertEqual(t, []byte("loaded"), r)
+	}
+	cached, err := cache.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte("loaded"), cached)
+}
+
+func TestGetOrLoadDoesNotCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	loadErr := errors.New("backend is down")
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, loadErr
+	}
+
+	// when
+	_, err1 := cache.GetOrLoad("key", loader)
+	_, err2 := cache.GetOrLoad("key", loader)
+
+	// then
+	assertEqual(t, loadErr, err1)
+	assertEqual(t, loadErr, err2)
+	assertEqual(t, 2, calls)
+	assertEqual(t, 0, cache.Len())
+}
+
+func TestNegativeCaching(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         10 * time.Second,
+		NegativeTTL:        2 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, &clock)
+	cache.Set("key", []byte("value"))
+
+	// when
+	err := cache.SetMiss("key")
+	noError(t, err)
+	value, err := cache.Get("key")
+
+	// then
+	assertEqual(t, ErrEntryNegativeCached, err)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, []byte(nil), value)
+	count := 0
+	cache.Range(func(*CacheEntry) error { count++; return nil })
+	assertEqual(t, 0, count)
+
+	// when
+	clock.set(3)
+	_, err = cache.Get("key")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, int64(2), cache.Stats().Misses)
+
+	// when
+	cache.Set("key", []byte("value2"))
+	value, err = cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value2"), value)
+}
+
+func TestNegativeMarkersAreNotCountedAsEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		NegativeTTL:        time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	cache.Set("key1", []byte("value"))
+	cache.Set("key2", []byte("value"))
+
+	// when
+	noError(t, cache.SetMiss("key2"))
+	noError(t, cache.SetMiss("absent1"))
+	noError(t, cache.SetMiss("absent2"))
+
+	// then
+	assertEqual(t, 1, cache.Len())
+
+	// when
+	cache.Set("absent1", []byte("value"))
+	noError(t, cache.Delete("key1"))
+
+	// then
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, true, cache.Verify() == nil)
+
+	// when
+	noError(t, cache.Reset())
+	noError(t, cache.SetMiss("absent3"))
+
+	// then
+	assertEqual(t, 0, cache.Len())
+}
+
+// waitForLoadWaiters blocks until given number of callers wait for in-flight load of the key.
+func waitForLoadWaiters(cache *BigCache, key string, waiters int) {
+	for {
+		cache.loads.Lock()
+		c, ok := cache.loads.calls[key]
+		done := ok && c.dups >= waiters
+		cache.loads.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetOrLoadSurvivesLoaderPanic(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = cache.GetOrLoad("key", func() ([]byte, error) {
+			close(started)
+			<-release
+			panic("loader failure")
+		})
+	}()
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, err := cache.GetOrLoad("key", func() ([]byte, error) { return []byte("waiter"), nil })
+		waiter <- err
+	}()
+	waitForLoadWaiters(cache, "key", 1)
+
+	// when
+	close(release)
+
+	// then
+	assertEqual(t, "loader failure", <-panicked)
+	assertEqual(t, true, errors.Is(<-waiter, ErrLoaderPanicked))
+	value, err := cache.GetOrLoad("other", func() ([]byte, error) { return []byte("value"), nil })
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	_, err = cache.GetOrLoad("key", func() ([]byte, error) { return []byte("value"), nil })
+	noError(t, err)
+}
+
+func TestGetOrLoadSurvivesLoaderGoexit(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	started, release, exited := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		_, _ = cache.GetOrLoad("key", func() ([]byte, error) {
+			close(started)
+			<-release
+			runtime.Goexit()
+			return nil, nil
+		})
+	}()
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, err := cache.GetOrLoad("key", func() ([]byte, error) { return []byte("waiter"), nil })
+		waiter <- err
+	}()
+	waitForLoadWaiters(cache, "key", 1)
+
+	// when
+	close(release)
+
+	// then
+	<-exited
+	assertEqual(t, true, errors.Is(<-waiter, ErrLoaderPanicked))
+	value, err := cache.GetOrLoad("key", func() ([]byte, error) { return []byte("value"), nil })
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+}
+
+func TestGetOrLoadCachesNotFoundNegatively(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := DefaultConfig(5 * time.Second)
+	config.NegativeTTL = time.Minute
+	cache, _ := NewBigCache(config)
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, ErrEntryNotFound
+	}
+
+	// when
+	_, err1 := cache.GetOrLoad("key", loader)
+	_, err2 := cache.GetOrLoad("key", loader)
+
+	// then
+	assertEqual(t, true, errors.Is(err1, ErrEntryNotFound))
+	assertEqual(t, ErrEntryNegativeCached, err2)
+	assertEqual(t, 1, calls)
+}
+
+func TestPauseAndResumeCleanup(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Second,
+		CleanWindow:        100 * time.Millisecond,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       256,
+	})
+	defer cache.Close()
+
+	// when
+	cache.PauseCleanup()
+	cache.Set("key", []byte("value"))
+	<-time.After(2500 * time.Millisecond)
+	value, err := cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+
+	// when
+	cache.ResumeCleanup()
+	<-time.After(300 * time.Millisecond)
+	_, err = cache.Get("key")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
+
+func TestCleanupActive(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Second,
+		CleanWindow:        100 * time.Millisecond,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       256,
+	}
+	cache, _ := NewBigCache(config)
+	config.CleanWindow = 0
+	manual, _ := NewBigCache(config)
+
+	// when
+	cache.PauseCleanup()
+
+	// then
+	assertEqual(t, true, cache.CleanupActive())
+	assertEqual(t, false, manual.CleanupActive())
+
+	// when
+	cache.Close()
+
+	// then
+	assertEqual(t, false, cache.CleanupActive())
+	for i := 0; i < 100 && atomic.LoadInt32(&cache.cleaning) != 0; i++ {
+		<-time.After(10 * time.Millisecond)
+	}
+	assertEqual(t, int32(0), atomic.LoadInt32(&cache.cleaning))
+}
+
+func TestEvictionWatermark(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             1,
+		LifeWindow:         100 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+	}
+	plain, _ := NewBigCache(config)
+	config.EvictionWatermark = 0.5
+	watermarked, _ := NewBigCache(config)
+	value := blob('a', 1024*100)
+
+	// when
+	fill := func(cache *BigCache) {
+		for i := 0; cache.Stats().EvictedNoSpace == 0; i++ {
+			cache.Set(fmt.Sprintf("key%d", i), value)
+		}
+	}
+	fill(plain)
+	fill(watermarked)
+
+	// then
+	assertEqual(t, true, watermarked.Stats().EvictedNoSpace > plain.Stats().EvictedNoSpace)
+	assertEqual(t, true, watermarked.Len() < plain.Len())
+	assertEqual(t, true, watermarked.shards[0].entries.used() <= 1024*1024/2+len(value)+(*CacheEntry).Size(nil))
+}
+
+func TestInvalidEvictionWatermark(t *testing.T) {
+	t.Parallel()
+
+	// when
+	cache, err := NewBigCache(Config{Shards: 1, EvictionWatermark: 1.5})
+
+	// then
+	assertEqual(t, (*BigCache)(nil), cache)
+	assertEqual(t, ErrInvalidWatermark, err)
+}
+
+func TestInitialShardCapacityBytes(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:                    2,
+		LifeWindow:                time.Second,
+		MaxEntriesInWindow:        2 * 1024,
+		MaxEntrySize:              1024,
+		InitialShardCapacityBytes: 4096,
+	}
+
+	// when
+	cache, _ := NewBigCache(config)
+
+	// then
+	assertEqual(t, 2*4096, cache.Capacity())
+
+	// when
+	config.InitialShardCapacityBytes = 4 * 1024 * 1024
+	config.HardMaxCacheSize = 1
+	cache, _ = NewBigCache(config)
+
+	// then
+	assertEqual(t, 1024*1024, cache.Capacity())
+}
+
+func TestAutomaticShardsNumber(t *testing.T) {
+	t.Parallel()
+
+	// when
+	cache, err := NewBigCache(Config{
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, true, isPowerOfTwo(len(cache.shards)))
+	assertEqual(t, true, len(cache.shards) >= runtime.GOMAXPROCS(0)*autoShardsPerProc)
+	assertEqual(t, len(cache.shards)-1, int(cache.shardMask))
+	noError(t, cache.Set("key", []byte("value")))
+}
+
+func TestNextPowerOfTwo(t *testing.T) {
+	t.Parallel()
+
+	assertEqual(t, []int{1, 1, 2, 4, 4, 8, 1024}, []int{nextPowerOfTwo(0), nextPowerOfTwo(1), nextPowerOfTwo(2),
+		nextPowerOfTwo(3), nextPowerOfTwo(4), nextPowerOfTwo(5), nextPowerOfTwo(1000)})
+}
+
+func TestRangeCopiesKeys(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("value"))
+
+	// when
+	var keys []string
+	cache.Range(func(ce *CacheEntry) error {
+		keys = append(keys, string(ce.Key))
+		return nil
+	})
+
+	// then
+	assertEqual(t, []string{"key"}, keys)
+}
+
+func TestGetInto(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("value"))
+	dst := make([]byte, 10)
+
+	// when
+	n, err := cache.GetInto("key", dst)
+
+	// then
+	noError(t, err)
+	assertEqual(t, 5, n)
+	assertEqual(t, []byte("value"), dst[:n])
+
+	// when
+	n, err = cache.GetInto("key", dst[:3])
+
+	// then
+	assertEqual(t, ErrBufferTooSmall, err)
+	assertEqual(t, 5, n)
+
+	// when
+	n, err = cache.GetInto("missing", dst)
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, 0, n)
+	assertEqual(t, int64(2), cache.Stats().Hits)
+	assertEqual(t, int64(1), cache.Stats().Misses)
+}
+
+func TestGetJSON(t *testing.T) {
+	t.Parallel()
+
+	// given
+	type record struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	cache.Set("record", []byte(`{"name":"value","count":3}`))
+	cache.Set("broken", []byte(`{"name":`))
+
+	// when
+	var r record
+	err := cache.GetJSON("record", &r)
+
+	// then
+	noError(t, err)
+	assertEqual(t, record{Name: "value", Count: 3}, r)
+
+	// when
+	err = cache.GetJSON("broken", &r)
+
+	// then
+	var syntaxErr *json.SyntaxError
+	assertEqual(t, true, errors.As(err, &syntaxErr))
+
+	// when
+	err = cache.GetJSON("absent", &r)
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, "entry not found: \"absent\"", err.Error())
+}
+
+func TestSetWithProcessing(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       1,
+		HardMaxCacheSize:   1,
+	})
+	cache.Set("key", []byte("old"))
+
+	// when
+	err := cache.SetWithProcessing("key", 5, func(dst []byte) error {
+		assertEqual(t, 5, len(dst))
+		copy(dst, "value")
+		return nil
+	})
+	value, err1 := cache.Get("key")
+
+	// then
+	noError(t, err)
+	noError(t, err1)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, 1, cache.Len())
+
+	// when
+	failure := errors.New("encoding failed")
+	err = cache.SetWithProcessing("key", 3, func(dst []byte) error { return failure })
+	value, err1 = cache.Get("key")
+
+	// then
+	assertEqual(t, failure, err)
+	noError(t, err1)
+	assertEqual(t, []byte("value"), value)
+
+	// when - reservation evicts old entries to make room
+	for i := 0; i < 4; i++ {
+		err = cache.SetWithProcessing(fmt.Sprintf("big%d", i), 1024*300, func(dst []byte) error {
+			copy(dst, blob(byte('a'+i), len(dst)))
+			return nil
+		})
+		noError(t, err)
+	}
+	value, err1 = cache.Get("big3")
+
+	// then
+	noError(t, err1)
+	assertEqual(t, blob('d', 1024*300), value)
+	_, err1 = cache.Get("big0")
+	assertEqual(t, true, errors.Is(err1, ErrEntryNotFound))
+	assertEqual(t, true, cache.Stats().EvictedNoSpace > 0)
+}
+
+func TestFreezeAndUnfreeze(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, &clock)
+	cache.Set("key", []byte("value"))
+
+	// when
+	cache.Freeze()
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+	value, err := cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, ErrCacheFrozen, cache.Set("key", []byte("other")))
+	assertEqual(t, ErrCacheFrozen, cache.Append("key", []byte("other")))
+	assertEqual(t, ErrCacheFrozen, cache.Delete("key"))
+	assertEqual(t, ErrCacheFrozen, cache.Reset())
+	assertEqual(t, ErrCacheFrozen, cache.Resize(2))
+	assertEqual(t, 0, cache.EvictOldest(1))
+	assertEqual(t, 1, cache.Len())
+
+	// when
+	cache.Unfreeze()
+	cache.cleanUp(clock.epoch())
+	_, err = cache.Get("key")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	noError(t, cache.Set("key", []byte("other")))
+}
+
+func TestShardGeometry(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:                    2,
+		LifeWindow:                5 * time.Second,
+		InitialShardCapacityBytes: 100,
+		Hasher:                    hashStub(0),
+	})
+
+	// when
+	cache.Set("a", []byte("value"))
+	cache.Set("b", []byte("value"))
+	cache.Delete("a")
+	geometry, err := cache.ShardGeometry(0)
+	empty, _ := cache.ShardGeometry(1)
+	_, invalidErr := cache.ShardGeometry(2)
+
+	// then
+	noError(t, err)
+	tail := 2 * (&CacheEntry{Key: []byte("a"), Data: []byte("value")}).Size()
+	assertEqual(t, ShardGeometry{Head: 0, Tail: tail, Right: tail, Count: 2, Capacity: 100}, geometry)
+	assertEqual(t, ShardGeometry{Capacity: 100}, empty)
+	assertEqual(t, ErrInvalidShardIndex, invalidErr)
+}
+
+func TestCacheHeadroom(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:                    4,
+		LifeWindow:                5 * time.Second,
+		InitialShardCapacityBytes: 1024,
+	})
+	entry := &CacheEntry{Key: []byte("key"), Data: []byte("value")}
+
+	// when
+	before := cache.Headroom()
+	cache.Set("key", []byte("value"))
+	after := cache.Headroom()
+
+	// then
+	assertEqual(t, cache.Capacity(), before)
+	assertEqual(t, before-entry.Size(), after)
+}
+
+func TestIndexMemory(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+	})
+	assertEqual(t, 0, cache.IndexMemory())
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Set("other", []byte("value"))
+
+	// then - one bucket for the hash and one for its chain with a single reference
+	assertEqual(t, 144+272+8, cache.IndexMemory())
+}
+
+func TestMapMemory(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		entries, memory int
+	}{
+		{0, 0},
+		{1, 144},
+		{6, 144},
+		{7, 288},
+		{13, 288},
+		{14, 576},
+		{1000000, 262144 * 144},
+	} {
+		assertEqual(t, tc.memory, mapMemory(tc.entries, 16))
+	}
+}
+
+func TestResetShard(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             8,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	shard := cache.ShardIndex("key0")
+	var inShard int
+	for i := 0; i < 100; i++ {
+		if cache.ShardIndex(fmt.Sprintf("key%d", i)) == shard {
+			inShard++
+		}
+	}
+
+	// when
+	err := cache.ResetShard(shard)
+
+	// then
+	noError(t, err)
+	assertEqual(t, 100-inShard, cache.Len())
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		_, err := cache.Get(key)
+		assertEqual(t, cache.ShardIndex(key) == shard, errors.Is(err, ErrEntryNotFound))
+	}
+	assertEqual(t, ErrInvalidShardIndex, cache.ResetShard(-1))
+	assertEqual(t, ErrInvalidShardIndex, cache.ResetShard(8))
+}
+
+func TestDeleteFunc(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("tenantA/%d", i), []byte("a"))
+		cache.Set(fmt.Sprintf("tenantB/%d", i), []byte("b"))
+	}
+	cache.Delete("tenantA/0")
+
+	// when
+	deleted := cache.DeleteFunc(func(key string, data []byte) bool {
+		return strings.HasPrefix(key, "tenantA/") && string(data) == "a"
+	})
+
+	// then
+	assertEqual(t, 9, deleted)
+	assertEqual(t, 10, cache.Len())
+	cache.Range(func(ce *CacheEntry) error {
+		assertEqual(t, true, strings.HasPrefix(string(ce.Key), "tenantB/"))
+		return nil
+	})
+}
+
+func TestMustNewBigCache(t *testing.T) {
+	t.Parallel()
+
+	// when
+	cache := MustNewBigCache(DefaultConfig(5 * time.Second))
+
+	// then
+	noError(t, cache.Set("key", []byte("value")))
+
+	// given
+	defer func() {
+		// then
+		assertEqual(t, ErrInvalidShardsNumber, recover())
+	}()
+
+	// when
+	MustNewBigCache(Config{Shards: 18})
+	t.Error("MustNewBigCache did not panic")
+}
+
+func TestNotFoundErrorCarriesKey(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	var notFound *NotFoundError
+
+	// when
+	_, err := cache.Get("missing")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, true, errors.As(err, &notFound))
+	assertEqual(t, "missing", notFound.Key)
+	assertEqual(t, `entry not found: "missing"`, err.Error())
+
+	// when
+	err = cache.Delete("gone")
+
+	// then
+	assertEqual(t, true, errors.As(err, &notFound))
+	assertEqual(t, "gone", notFound.Key)
+
+	// when
+	_, err = cache.GetHashed(1)
+
+	// then
+	assertEqual(t, true, errors.As(err, &notFound))
+	assertEqual(t, "", notFound.Key)
+	assertEqual(t, "entry not found", err.Error())
+}
+
+func TestRangeInfo(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	clock := &mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, clock)
+	cache.SetWithTag("old", []byte("value"), 1)
+	clock.set(104)
+	cache.Set("new", []byte("value"))
+	clock.set(112)
+	infos := make(map[string]EntryInfo)
+
+	// when
+	err := cache.RangeInfo(func(ce *CacheEntry, info EntryInfo) error {
+		infos[string(ce.Key)] = info
+		return nil
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, map[string]EntryInfo{
+		"old": {Timestamp: 100, Tag: 1, Age: 12 * time.Second},
+		"new": {Timestamp: 104, Age: 8 * time.Second, TTL: 2 * time.Second},
+	}, infos)
+}
+
+func TestRangeUnsafe(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		NegativeTTL:        time.Second,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte{byte(i)})
+	}
+	cache.Delete("key0")
+	cache.SetMiss("missing")
+
+	// when
+	var count, sum int
+	err := cache.RangeUnsafe(func(ce *CacheEntry) error {
+		count++
+		sum += int(ce.Data[0])
+		return nil
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, 9, count)
+	assertEqual(t, 45, sum)
+
+	// when
+	count = 0
+	err = cache.RangeUnsafe(func(ce *CacheEntry) error {
+		count++
+		return ErrEntryNotFound
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, 1, count)
+}
+
+func TestRangePooled(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		NegativeTTL:        time.Second,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	cache.Set("nil", nil)
+	cache.Delete("key0")
+	cache.SetMiss("missing")
+
+	// when
+	found := make(map[string]string)
+	err := cache.RangePooled(func(ce *CacheEntry) error {
+		if ce.Data == nil {
+			found[ce.CopyKey()] = "<nil>"
+			return nil
+		}
+		found[ce.CopyKey()] = string(ce.Data)
+		return nil
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, 10, len(found))
+	assertEqual(t, "<nil>", found["nil"])
+	for i := 1; i < 10; i++ {
+		assertEqual(t, fmt.Sprintf("value%d", i), found[fmt.Sprintf("key%d", i)])
+	}
+}
+
+func TestRangePooledAllocatesLess(t *testing.T) {
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	nop := func(*CacheEntry) error { return nil }
+
+	// when
+	copied := testing.AllocsPerRun(10, func() { _ = cache.Range(nop) })
+	pooled := testing.AllocsPerRun(10, func() { _ = cache.RangePooled(nop) })
+
+	// then - only entry headers are allocated
+	assertEqual(t, true, copied >= 300)
+	assertEqual(t, true, pooled < 110)
+}
+
+func TestRangeOrdered(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	}, &clock)
+	for i := 0; i < 20; i++ {
+		clock.set(uint64(i))
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	cache.Delete("key3")
+	cache.Set("key5", []byte("updated"))
+
+	// when
+	var keys []string
+	err := cache.RangeOrdered(func(ce *CacheEntry) error {
+		keys = append(keys, string(ce.Key))
+		return nil
+	})
+
+	// then
+	noError(t, err)
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		if i != 3 && i != 5 {
+			expected = append(expected, fmt.Sprintf("key%d", i))
+		}
+	}
+	assertEqual(t, append(expected, "key5"), keys)
+}
+
+func TestGetOldestAndNewest(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             8,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	}, &clock)
+
+	// when
+	_, errOldest := cache.GetOldest()
+	_, errNewest := cache.GetNewest()
+
+	// then
+	assertEqual(t, ErrEntryNotFound, errOldest)
+	assertEqual(t, ErrEntryNotFound, errNewest)
+
+	// given
+	for i := 0; i < 20; i++ {
+		clock.set(uint64(i + 1))
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	cache.Delete("key0")
+
+	// when
+	oldest, errOldest := cache.GetOldest()
+	newest, errNewest := cache.GetNewest()
+
+	// then
+	noError(t, errOldest)
+	noError(t, errNewest)
+	assertEqual(t, "key1", string(oldest.Key))
+	assertEqual(t, []byte("value1"), oldest.Data)
+	assertEqual(t, uint64(2), oldest.TS)
+	assertEqual(t, "key19", string(newest.Key))
+	assertEqual(t, []byte("value19"), newest.Data)
+	assertEqual(t, uint64(20), newest.TS)
+}
+
+func TestExpiringWithin(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             4,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		NegativeTTL:        time.Second,
+	}, &clock)
+	for i := 0; i < 10; i++ {
+		clock.set(uint64(i))
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	cache.SetMiss("missing")
+	cache.Delete("key0")
+	clock.set(12)
+
+	// then
+	assertEqual(t, 2, cache.ExpiringWithin(0))
+	assertEqual(t, 4, cache.ExpiringWithin(2*time.Second))
+	assertEqual(t, 9, cache.ExpiringWithin(time.Minute))
+}
+
+func TestPreallocateToMax(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             2,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+		HardMaxCacheSize:   1,
+		PreallocateToMax:   true,
+	})
+	capacity := cache.Capacity()
+
+	// when
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), blob('a', 1024))
+	}
+
+	// then
+	assertEqual(t, 1024*1024, capacity)
+	assertEqual(t, capacity, cache.Capacity())
+	assertEqual(t, true, cache.Stats().EvictedNoSpace > 0)
+	assertEqual(t, int64(0), cache.Stats().Reallocations)
+}
+
+func TestPreallocateToMaxRequiresLimit(t *testing.T) {
+	t.Parallel()
+
+	// when
+	cache, err := NewBigCache(Config{Shards: 1, PreallocateToMax: true})
+
+	// then
+	assertEqual(t, (*BigCache)(nil), cache)
+	assertEqual(t, ErrInvalidPreallocation, err)
+}
+
+func TestMinEntriesPerShard(t *testing.T) {
+	t.Parallel()
+
+	// when
+	cache, _ := NewBigCache(Config{Shards: 1, MaxEntrySize: 100, MinEntriesPerShard: 2})
+	defaults, _ := NewBigCache(Config{Shards: 1, MaxEntrySize: 100})
+	invalid, err := NewBigCache(Config{Shards: 1, MinEntriesPerShard: -1})
+
+	// then
+	assertEqual(t, 200, cache.Capacity())
+	assertEqual(t, 1000, defaults.Capacity())
+	assertEqual(t, (*BigCache)(nil), invalid)
+	assertEqual(t, ErrInvalidMinEntries, err)
+}
+
+func TestReallocationsStats(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+	})
+
+	// when
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), blob('a', 1024))
+	}
+
+	// then
+	assertEqual(t, true, cache.Stats().Reallocations >= 3)
+}
+
+func TestReclaimedTombstonesStats(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, clock)
+	cache.Set("a", []byte("value"))
+	cache.Set("b", []byte("value"))
+	cache.Set("c", []byte("value"))
+	cache.Delete("a")
+	cache.Delete("b")
+
+	// when
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, int64(2), cache.Stats().ReclaimedTombstones)
+	assertEqual(t, int64(1), cache.Stats().EvictedExpired)
+	assertEqual(t, 0, cache.Len())
+}
+
+func TestBytesWrittenAndReadStats(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	buf := make([]byte, 16)
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Append("key", []byte("123"))
+	cache.Get("key")
+	cache.GetInto("key", buf)
+	cache.Get("missing")
+
+	// then - only appended bytes are written in place
+	stats := cache.Stats()
+	assertEqual(t, int64(5+3), stats.BytesWritten)
+	assertEqual(t, int64(8+8), stats.BytesRead)
+}
+
+func TestSizeHistogram(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		NegativeTTL:        time.Second,
+	})
+	cache.Set("empty", []byte{})
+	cache.Set("one", []byte("a"))
+	cache.Set("three", []byte("abc"))
+	cache.Set("four", []byte("abcd"))
+	cache.Set("kilo", blob('a', 1024))
+	cache.SetMiss("missing")
+
+	// when
+	histogram := cache.SizeHistogram()
+
+	// then
+	assertEqual(t, []int{1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 1}, histogram)
+}
+
+func TestLargestEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		NegativeTTL:        time.Second,
+	})
+	for i := 0; i < 50; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), blob('a', i))
+	}
+	cache.SetHashed(42, blob('a', 2048))
+	cache.Set("huge", blob('a', 4096))
+	cache.SetMiss("missing")
+	size := func(key string, n int) int {
+		return (&CacheEntry{Key: []byte(key), Data: make([]byte, n)}).Size()
+	}
+
+	// when
+	largest := cache.LargestEntries(4)
+
+	// then
+	assertEqual(t, []EntrySize{
+		{Key: "huge", Hash: cache.hash.Sum64("huge"), Size: size("huge", 4096)},
+		{Hash: 42, Size: size("", 2048)},
+		{Key: "key49", Hash: cache.hash.Sum64("key49"), Size: size("key49", 49)},
+		{Key: "key48", Hash: cache.hash.Sum64("key48"), Size: size("key48", 48)},
+	}, largest)
+	assertEqual(t, 52, len(cache.LargestEntries(100)))
+	assertEqual(t, 0, len(cache.LargestEntries(0)))
+}
+
+func TestFutureTimestampIsNotExpired(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		NegativeTTL:        5 * time.Second,
+	}, &clock)
+	cache.Set("key", []byte("value"))
+	cache.SetMiss("missing")
+
+	// when - clock moves back
+	clock.set(50)
+	cache.cleanUp(clock.epoch())
+	cache.Set("other", []byte("value"))
+	value, err := cache.Get("key")
+	_, missErr := cache.Get("missing")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, ErrEntryNegativeCached, missErr)
+	assertEqual(t, int64(0), cache.Stats().EvictedExpired)
+}
+
+func TestPeekDoesNotChangeStats(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+	})
+	cache.Set("liquid", []byte("value"))
+	stats := cache.Stats()
+
+	// when
+	value, err := cache.Peek("liquid")
+	_, missErr := cache.Peek("missing")
+	_, collisionErr := cache.Peek("costarring")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, true, errors.Is(missErr, ErrEntryNotFound))
+	assertEqual(t, true, errors.Is(collisionErr, ErrEntryNotFound))
+	assertEqual(t, stats, cache.Stats())
+}
+
+func TestDisableStats(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		DisableStats:       true,
+	})
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Get("key")
+	cache.Get("missing")
+	cache.Delete("key")
+	cache.Delete("key")
+
+	// then
+	assertEqual(t, Stats{}, cache.Stats())
+}
+
+func TestSetWithTag(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	clock := &mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, clock)
+
+	// when
+	cache.SetWithTag("tagged", []byte("value"), 42)
+	cache.Set("plain", []byte("value"))
+	cache.Append("tagged", []byte("-appended"))
+	value, info, err := cache.GetWithInfo("tagged")
+	_, plain, _ := cache.GetWithInfo("plain")
+	_, _, missErr := cache.GetWithInfo("missing")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value-appended"), value)
+	assertEqual(t, EntryInfo{Timestamp: 100, Tag: 42, TTL: 5 * time.Second}, info)
+	assertEqual(t, uint16(0), plain.Tag)
+	assertEqual(t, true, errors.Is(missErr, ErrEntryNotFound))
+
+	// when
+	var tag uint16
+	cache.GetWithProcessing("tagged", func(ce *CacheEntry) error {
+		tag = ce.Tag
+		return nil
+	})
+
+	// then
+	assertEqual(t, uint16(42), tag)
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	increment := func(old []byte, found bool) ([]byte, bool) {
+		var n uint64
+		if found {
+			n = binary.LittleEndian.Uint64(old)
+		}
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, n+1)
+		return buf, true
+	}
+
+	// when
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				noError(t, cache.Update("counter", increment))
+			}
+		}()
+	}
+	wg.Wait()
+	value, err := cache.Get("counter")
+
+	// then
+	noError(t, err)
+	assertEqual(t, uint64(1000), binary.LittleEndian.Uint64(value))
+
+	// when
+	cache.SetWithTag("tagged", []byte("value"), 7)
+	err = cache.Update("tagged", func(old []byte, found bool) ([]byte, bool) {
+		return append(old, "-updated"...), true
+	})
+	value, info, _ := cache.GetWithInfo("tagged")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value-updated"), value)
+	assertEqual(t, uint16(7), info.Tag)
+
+	// when
+	err = cache.Update("tagged", func(old []byte, found bool) ([]byte, bool) {
+		return nil, false
+	})
+	_, getErr := cache.Get("tagged")
+	missErr := cache.Update("missing", func(old []byte, found bool) ([]byte, bool) {
+		assertEqual(t, false, found)
+		return nil, false
+	})
+
+	// then
+	noError(t, err)
+	noError(t, missErr)
+	assertEqual(t, true, errors.Is(getErr, ErrEntryNotFound))
+	assertEqual(t, 1, cache.Len())
+}
+
+func TestAppendGrowsLastEntryInPlace(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	clock := &mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, clock)
+	shard := cache.shards[0]
+	cache.SetWithTag("key", []byte("a"), 7)
+
+	// when
+	clock.set(101)
+	for i := 0; i < 10; i++ {
+		noError(t, cache.Append("key", []byte("b")))
+	}
+	noError(t, cache.Update("key", func(old []byte, found bool) ([]byte, bool) {
+		return append(old[1:], "cc"...), true
+	}))
+	value, info, err := cache.GetWithInfo("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("bbbbbbbbbbcc"), value)
+	assertEqual(t, EntryInfo{Timestamp: 101, Tag: 7, TTL: 5 * time.Second}, info)
+	assertEqual(t, 1, shard.entries.len())
+
+	// when
+	cache.Set("other", []byte("value"))
+	noError(t, cache.Append("key", []byte("d")))
+	value, _ = cache.Get("key")
+
+	// then
+	assertEqual(t, []byte("bbbbbbbbbbccd"), value)
+	assertEqual(t, 3, shard.entries.len()) // not the last entry, stored again
+	assertEqual(t, 2, cache.Len())
+}
+
+func TestAppendMulti(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	cipher, _ := NewAESGCMCipher(make([]byte, 16))
+	for _, c := range []Cipher{nil, cipher} {
+		// given
+		cache, _ := NewBigCache(Config{
+			Shards:             1,
+			LifeWindow:         5 * time.Second,
+			MaxEntriesInWindow: 10,
+			MaxEntrySize:       256,
+			Cipher:             c,
+		})
+		shard := cache.shards[0]
+
+		// when - key is absent
+		noError(t, cache.AppendMulti("key", [][]byte{[]byte("a"), []byte("bc"), nil, []byte("d")}))
+		value, err := cache.Get("key")
+
+		// then
+		noError(t, err)
+		assertEqual(t, []byte("abcd"), value)
+		assertEqual(t, 1, shard.entries.len())
+
+		// when - chunks follow existing data in order
+		cache.SetWithTag("other", []byte("value"), 3)
+		noError(t, cache.AppendMulti("other", [][]byte{[]byte("-1"), []byte("-2"), []byte("-3")}))
+		value, info, err := cache.GetWithInfo("other")
+
+		// then - stored once
+		noError(t, err)
+		assertEqual(t, []byte("value-1-2-3"), value)
+		assertEqual(t, uint16(3), info.Tag)
+		assertEqual(t, 2, cache.Len())
+		assertEqual(t, int64(3), cache.Stats().Hits)
+	}
+}
+
+func TestAppendMultiOnFrozenCache(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	cache.Freeze()
+
+	// when
+	err := cache.AppendMulti("key", [][]byte{[]byte("a")})
+
+	// then
+	assertEqual(t, ErrCacheFrozen, err)
+}
+
+func TestWarmup(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ml := &mockedLogger{}
+	warmup := map[string][]byte{"big": blob('a', 2*1024*1024)}
+	for i := 0; i < 100; i++ {
+		warmup[fmt.Sprintf("key%d", i)] = []byte(fmt.Sprintf("value%d", i))
+	}
+
+	// when
+	cache, err := newBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		Logger:             ml,
+		Warmup:             warmup,
+	}, &mockedClock{value: 100})
+
+	// then
+	noError(t, err)
+	assertEqual(t, 100, cache.Len())
+	for i := 0; i < 100; i++ {
+		value, info, err := cache.GetWithInfo(fmt.Sprintf("key%d", i))
+		noError(t, err)
+		assertEqual(t, []byte(fmt.Sprintf("value%d", i)), value)
+		assertEqual(t, uint64(100), info.Timestamp)
+	}
+	_, err = cache.Get("big")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, "unable to warm up entry %q: %v", ml.lastFormat)
+}
+
+func TestKeyTooLong(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	longest := string(blob('a', 65535))
+	tooLong := string(blob('a', 70000))
+
+	// when
+	err := cache.Set(longest, []byte("value"))
+	tooLongErr := cache.Set(tooLong, []byte("value"))
+	processingErr := cache.SetWithProcessing(tooLong, 1, func([]byte) error { return nil })
+	value, getErr := cache.Get(longest)
+
+	// then
+	noError(t, err)
+	noError(t, getErr)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, true, errors.Is(tooLongErr, ErrKeyTooLong))
+	assertEqual(t, true, len(tooLongErr.Error()) < 200)
+	assertEqual(t, true, errors.Is(processingErr, ErrKeyTooLong))
+	assertEqual(t, 1, cache.Len())
+}
+
+func TestMaxKeyLength(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		MaxKeyLength:       4,
+	})
+
+	// when
+	err := cache.Set("key", []byte("value"))
+	tooLongErr := cache.Append("longer", []byte("value"))
+
+	// then
+	noError(t, err)
+	assertEqual(t, true, errors.Is(tooLongErr, ErrKeyTooLong))
+}
+
+func TestKeyValidator(t *testing.T) {
+	t.Parallel()
+
+	// given
+	errInvalidKey := errors.New("key must start with user:")
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		KeyValidator: func(key string) error {
+			if !strings.HasPrefix(key, "user:") {
+				return errInvalidKey
+			}
+			return nil
+		},
+	})
+
+	// when
+	err := cache.Set("user:1", []byte("value"))
+	setErr := cache.Set("invalid", []byte("value"))
+	_, getErr := cache.Get("invalid")
+	appendErr := cache.Append("invalid", []byte("value"))
+	deleteErr := cache.Delete("invalid")
+	value, _ := cache.Get("user:1")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, errInvalidKey, setErr)
+	assertEqual(t, errInvalidKey, getErr)
+	assertEqual(t, errInvalidKey, appendErr)
+	assertEqual(t, errInvalidKey, deleteErr)
+	assertEqual(t, 1, cache.Len())
+}
+
+func TestBytesKeys(t *testing.T) {
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	key := []byte("a key which is long enough to be allocated on heap when converted")
+
+	// when
+	err := cache.SetBytes(key, []byte("value"))
+	value, getErr := cache.Get(string(key))
+	bytesValue, bytesErr := cache.GetBytes(key)
+	allocs := testing.AllocsPerRun(100, func() {
+		cache.GetBytes(key)
+	})
+	deleteErr := cache.DeleteBytes(key)
+	_, missErr := cache.GetBytes(key)
+	missDeleteErr := cache.DeleteBytes(key)
+
+	// then
+	noError(t, err)
+	noError(t, getErr)
+	noError(t, bytesErr)
+	noError(t, deleteErr)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, []byte("value"), bytesValue)
+	assertEqual(t, 1.0, allocs) // copy of value
+	assertEqual(t, &NotFoundError{Key: string(key)}, missErr)
+	assertEqual(t, &NotFoundError{Key: string(key)}, missDeleteErr)
+}
@@ -0,0 +1,67 @@
+package bigcache
+
+import "sync/atomic"
+
+const bloomHashes = 3 // Number of counters touched by every hash
+
+// bloomFilter is counting Bloom filter over already hashed keys. Counters allow removal, so filter could follow
+// shard's hashmap precisely. Mutations happen under shard write lock, lookups are lock free.
+// NOTE: filter answers "definitely absent" or "maybe present" - positive answer still requires real lookup.
+type bloomFilter struct {
+	counters []uint32
+	used     int64 // number of non zero counters
+}
+
+func newBloomFilter(size int) *bloomFilter {
+	if size <= 0 {
+		return nil
+	}
+	return &bloomFilter{counters: make([]uint32, size)}
+}
+
+// position uses double hashing to derive bloomHashes positions from a single 64 bit hash.
+func (f *bloomFilter) position(hash uint64, i int) int {
+	h1, h2 := hash&0xFFFF_FFFF, (hash>>32)|1
+	return int((h1 + uint64(i)*h2) % uint64(len(f.counters)))
+}
+
+func (f *bloomFilter) add(hash uint64) {
+	for i := 0; i < bloomHashes; i++ {
+		if atomic.AddUint32(&f.counters[f.position(hash, i)], 1) == 1 {
+			atomic.AddInt64(&f.used, 1)
+		}
+	}
+}
+
+func (f *bloomFilter) remove(hash uint64) {
+	for i := 0; i < bloomHashes; i++ {
+		p := f.position(hash, i)
+		if atomic.LoadUint32(&f.counters[p]) == 0 {
+			continue
+		}
+		if atomic.AddUint32(&f.counters[p], ^uint32(0)) == 0 {
+			atomic.AddInt64(&f.used, -1)
+		}
+	}
+}
+
+func (f *bloomFilter) mayContain(hash uint64) bool {
+	for i := 0; i < bloomHashes; i++ {
+		if atomic.LoadUint32(&f.counters[f.position(hash, i)]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) reset() {
+	for i := range f.counters {
+		atomic.StoreUint32(&f.counters[i], 0)
+	}
+	atomic.StoreInt64(&f.used, 0)
+}
+
+// fillRatio returns part of counters in use - the closer it gets to 1 the more false positives filter produces.
+func (f *bloomFilter) fillRatio() float64 {
+	return float64(atomic.LoadInt64(&f.used)) / float64(len(f.counters))
+}
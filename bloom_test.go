@@ -0,0 +1,90 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBloomFilterAddRemove(t *testing.T) {
+	t.Parallel()
+
+	// given
+	f := newBloomFilter(1024)
+
+	// when
+	f.add(42)
+	f.add(42)
+
+	// then
+	assertEqual(t, true, f.mayContain(42))
+	assertEqual(t, false, f.mayContain(43))
+	assertEqual(t, float64(bloomHashes)/1024, f.fillRatio())
+
+	// when
+	f.remove(42)
+
+	// then
+	assertEqual(t, true, f.mayContain(42))
+
+	// when
+	f.remove(42)
+	f.remove(42)
+
+	// then
+	assertEqual(t, false, f.mayContain(42))
+	assertEqual(t, float64(0), f.fillRatio())
+}
+
+func TestBloomFilterDisabled(t *testing.T) {
+	t.Parallel()
+
+	assertEqual(t, (*bloomFilter)(nil), newBloomFilter(0))
+}
+
+func TestCacheWithBloomFilter(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		BloomFilterSize:    1024,
+	}, &clock)
+
+	// when
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+
+	// then
+	for i := 0; i < 100; i++ {
+		value, err := cache.Get(fmt.Sprintf("key%d", i))
+		noError(t, err)
+		assertEqual(t, []byte("value"), value)
+	}
+	assertEqual(t, true, cache.Stats().BloomFillRatio > 0)
+
+	// when
+	cache.Delete("key0")
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	for i := 0; i < 100; i++ {
+		_, err := cache.Get(fmt.Sprintf("key%d", i))
+		assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	}
+	assertEqual(t, float64(0), cache.Stats().BloomFillRatio)
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Reset()
+
+	// then
+	assertEqual(t, float64(0), cache.Stats().BloomFillRatio)
+}
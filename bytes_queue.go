@@ -3,6 +3,8 @@ package bigcache
 import (
 	"bytes"
 	"errors"
+	"runtime"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,29 +26,65 @@ type bytesQueue struct {
 	tail        qref
 	right       qref
 	logger      Logger
+	storage     Storage
+	expansions  int64  // number of array reallocations, read atomically by statistics
+	popped      uint64 // number of entries popped from head, lets callers tell if remembered position is still valid
+	logAbove    int    // reallocations to capacity above it are logged, 0 means they never are
 }
 
 // newBytesQueue initialize new queue.
 // Initial capacity is used in bytes array allocation.
 func newBytesQueue(initialCapacity, maxCapacity int, logger Logger) *bytesQueue {
-	return &bytesQueue{
-		array:       make([]byte, initialCapacity),
+	q, _ := newBytesQueueWithStorage(initialCapacity, maxCapacity, logger, heapStorage{})
+	return q
+}
+
+// newBytesQueueWithStorage initialize new queue which array is allocated by storage.
+func newBytesQueueWithStorage(initialCapacity, maxCapacity int, logger Logger, storage Storage) (*bytesQueue, error) {
+	array, err := storage.Allocate(initialCapacity)
+	if err != nil {
+		return nil, err
+	}
+	q := &bytesQueue{
+		array:       array,
 		maxCapacity: maxCapacity,
 		logger:      logger,
+		storage:     storage,
+	}
+	if _, heap := storage.(heapStorage); !heap {
+		// queue could be referenced by shards replaced by Resize but still used by Range, so array is released
+		// only when nobody can access it anymore, unless cache is closed before
+		runtime.SetFinalizer(q, func(q *bytesQueue) {
+			_ = q.storage.Release(q.array)
+		})
 	}
+	return q, nil
 }
 
 // Push copies entry to the end of queue and moves tail. Expands backing array by allocating more space if needed.
 // Returns index for pushed data or error if maximum size queue limit is reached.
 func (q *bytesQueue) push(ce *CacheEntry) (qref, error) {
+	ref, err := q.alloc(ce.Size())
+	if err != nil {
+		return ref, err
+	}
+	ref.write(q.array, ce)
+	return ref, nil
+}
+
+// alloc reserves size bytes at the end of queue and moves tail. Expands backing array by allocating more space if needed.
+// Returns index for reserved space or error if maximum size queue limit is reached.
+// NOTE: caller is responsible for writing entry into reserved space before queue is used again.
+func (q *bytesQueue) alloc(size int) (qref, error) {
 
-	size, blobSize := ce.Size(), (*CacheEntry).Size(nil)
+	blobSize := (*CacheEntry).Size(nil)
 
 	if q.tail >= q.head {
 		// o___hDDDDDDDDtr___c
-		// check after tail
+		// check after tail, entry may fill the space up to the end of array exactly
 		if cap(q.array)-q.tail.idx() < size {
-			// check before head
+			// check before head, at least blobSize bytes must be left between wrapped tail and head: tail never
+			// reaches head, so they are equal only when queue is empty, and expand plugs the gap with empty entry
 			if q.head.idx()-blobSize >= size {
 				q.tail.wrap()
 			} else if err := q.expand(size); err != nil {
@@ -55,7 +93,7 @@ func (q *bytesQueue) push(ce *CacheEntry) (qref, error) {
 		}
 	} else {
 		// oDDDt________hDDDrc
-		// see if entry fits between head and tail
+		// see if entry fits between head and tail, leaving room for the plug like above
 		if q.head.sub(q.tail)-blobSize < size {
 			if err := q.expand(size); err != nil {
 				return 0, err
@@ -63,8 +101,6 @@ func (q *bytesQueue) push(ce *CacheEntry) (qref, error) {
 		}
 	}
 
-	// store entry in cache
-	q.tail.write(q.array, ce)
 	// move tail to the next position
 	ref := q.tail.move(size)
 	// move end of the data marker
@@ -75,10 +111,43 @@ func (q *bytesQueue) push(ce *CacheEntry) (qref, error) {
 	return ref, nil
 }
 
-// reallocates array keeping all existing indices unchanged.
+// grow extends entry kept under r by extra bytes without moving it. This is possible only when it is the last entry
+// in the queue and free space right after it is big enough, false is reported otherwise and queue is left intact.
+func (q *bytesQueue) grow(r qref, extra int) bool {
+	size := r.size(q.array)
+	if q.count == 0 || r.idx()+size != q.tail.idx() {
+		return false
+	}
+	if q.tail >= q.head {
+		// o___hDDDDDDDDtr___c
+		if cap(q.array)-q.tail.idx() < extra {
+			return false
+		}
+	} else {
+		// oDDDt________hDDDrc
+		// keep room for plug between tail and head, same as alloc does
+		if q.head.sub(q.tail)-(*CacheEntry).Size(nil) < extra {
+			return false
+		}
+	}
+	r.resize(q.array, size+extra)
+	q.tail.move(extra)
+	if q.tail > q.head {
+		q.right = q.tail
+	}
+	return true
+}
+
+// fits reports if entry of given size could be stored in the queue when it is empty, taking its size limit into account.
+func (q *bytesQueue) fits(size int) bool {
+	capacity := cap(q.array)
+	return q.maxCapacity <= 0 || size <= capacity || capacity+size <= q.maxCapacity
+}
+
+// reallocates array keeping all existing indices unchanged. Array may grow to exactly maxCapacity.
 func (q *bytesQueue) expand(minimum int) error {
 
-	if q.maxCapacity > 0 && cap(q.array)+minimum >= q.maxCapacity {
+	if q.maxCapacity > 0 && cap(q.array)+minimum > q.maxCapacity {
 		return ErrQueueFull
 	}
 
@@ -97,10 +166,26 @@ func (q *bytesQueue) expand(minimum int) error {
 	}
 
 	old := q.array
-	q.array = make([]byte, capacity)
+	grower, grows := q.storage.(growingStorage)
+	var (
+		array []byte
+		err   error
+	)
+	if grows {
+		array, err = grower.Grow(old, capacity)
+	} else {
+		array, err = q.storage.Allocate(capacity)
+	}
+	if err != nil {
+		return err
+	}
+	q.array = array
+	atomic.AddInt64(&q.expansions, 1)
 
 	if q.right != 0 {
-		copy(q.array, old[:q.right])
+		if !grows {
+			copy(q.array, old[:q.right])
+		}
 		if q.tail < q.head {
 			// o___hDDDDDDDDtr_c
 			// push
@@ -116,7 +201,16 @@ func (q *bytesQueue) expand(minimum int) error {
 		}
 	}
 
-	q.logger.Printf("Allocated new queue in %s; Capacity: %d \n", time.Since(start), capacity)
+	// old array is only accessed under shard lock, so it could be released right away
+	if !grows {
+		if err := q.storage.Release(old); err != nil {
+			q.logger.Printf("Unable to release queue memory: %v\n", err)
+		}
+	}
+
+	if q.logAbove > 0 && capacity > q.logAbove {
+		leveled(q.logger).Debugf("Allocated new queue in %s; Capacity: %d \n", time.Since(start), capacity)
+	}
 	return nil
 }
 
@@ -137,9 +231,28 @@ func (q *bytesQueue) pop() (qref, error) {
 		q.right = q.tail
 	}
 	q.count--
+	q.popped++
 	return ref, nil
 }
 
+// walk calls f for every entry in the queue from head to tail (including deleted ones and plugs) until f returns false.
+func (q *bytesQueue) walk(f func(qref) bool) {
+	q.walkFrom(q.head, 0, func(r qref, _ int) bool { return f(r) })
+}
+
+// walkFrom is walk starting at entry r which is i-th entry from head, f gets position of every entry from head.
+func (q *bytesQueue) walkFrom(r qref, i int, f func(r qref, i int) bool) {
+	for ; i < q.count; i++ {
+		if !r.valid(q.array) || !f(r, i) {
+			return
+		}
+		r.next(q.array)
+		if r == q.right {
+			r.wrap()
+		}
+	}
+}
+
 // peek checks that reference could be read.
 func (q *bytesQueue) peek(r qref) error {
 	if q.count == 0 {
@@ -176,12 +289,27 @@ func (q *bytesQueue) getHash(r qref) uint64 {
 	return r.hash(q.array)
 }
 
+func (q *bytesQueue) getFlags(r qref) entryFlags {
+	return r.flags(q.array)
+}
+
+func (q *bytesQueue) getTag(r qref) uint16 {
+	return r.tag(q.array)
+}
+
+func (q *bytesQueue) getSize(r qref) int {
+	return r.size(q.array)
+}
+
 func (q *bytesQueue) getKey(r qref) []byte {
 	return r.key(q.array)
 }
 
 // Returns copy of entry's data safe to use outside of shard lock.
 func (q *bytesQueue) getDataCopy(r qref) []byte {
+	if r.flags(q.array)&flagNil != 0 {
+		return nil
+	}
 	return append([]byte{}, r.data(q.array)...)
 }
 
@@ -203,6 +331,37 @@ func (q *bytesQueue) reset() {
 	q.tail, q.head, q.right, q.count = 0, 0, 0, 0
 }
 
+// release returns array to storage leaving queue empty.
+func (q *bytesQueue) release() error {
+	q.reset()
+	array := q.array
+	q.array = []byte{}
+	return q.storage.Release(array)
+}
+
+// used returns number of bytes occupied by entries (including deleted ones) between head and tail.
+func (q *bytesQueue) used() int {
+	if q.count == 0 {
+		return 0
+	}
+	if q.tail > q.head {
+		return q.tail.sub(q.head)
+	}
+	return q.right.sub(q.head) + q.tail.idx()
+}
+
+// headroom returns number of bytes which could be allocated before array has to be expanded. Entries are never
+// split, so space left after tail is wasted when entry does not fit there and it is written at the beginning.
+func (q *bytesQueue) headroom() int {
+	blobSize := (*CacheEntry).Size(nil)
+	if q.tail >= q.head {
+		// o___hDDDDDDDDtr___c
+		return cap(q.array) - q.tail.idx() + max(q.head.idx()-blobSize, 0)
+	}
+	// oDDDt________hDDDrc
+	return max(q.head.sub(q.tail)-blobSize, 0)
+}
+
 // cap returns number of allocated bytes for queue.
 func (q *bytesQueue) cap() int {
 	return cap(q.array)
@@ -121,10 +121,10 @@ func TestReuseAvailableSpace(t *testing.T) {
 	queue := newBytesQueue(110, 0, newNopLogger())
 
 	// when
-	queue.push(makeCacheBlob('a', 48))
-	queue.push(makeCacheBlob('b', 8))
+	queue.push(makeCacheBlob('a', 44))
+	queue.push(makeCacheBlob('b', 4))
 	queue.pop()
-	queue.push(makeCacheBlob('c', 8))
+	queue.push(makeCacheBlob('c', 4))
 
 	// then
 	assertEqual(t, 110, queue.cap())
@@ -135,7 +135,7 @@ func TestReuseAvailableSpace(t *testing.T) {
 	noError(t, err)
 	noError(t, err1)
 
-	assertEqual(t, makeCacheBlob('b', 8), ce)
+	assertEqual(t, makeCacheBlob('b', 4), ce)
 }
 
 func TestAllocateAdditionalSpace(t *testing.T) {
@@ -145,8 +145,8 @@ func TestAllocateAdditionalSpace(t *testing.T) {
 	queue := newBytesQueue(32, 0, newNopLogger())
 
 	// when
-	queue.push(makeCacheBlob('a', 8))
-	queue.push(makeCacheBlob('b', 8))
+	queue.push(makeCacheBlob('a', 2))
+	queue.push(makeCacheBlob('b', 2))
 
 	// then
 	assertEqual(t, 64, queue.cap())
@@ -155,24 +155,24 @@ func TestAllocateAdditionalSpace(t *testing.T) {
 func TestAllocateAdditionalSpaceForInsufficientFreeFragmentedSpaceWhereHeadIsBeforeTail(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
-	blobA := makeCacheBlob('a', 3) // 25 bytres
+	blobA := makeCacheBlob('a', 3) // 32 bytes
 	assertEqual(t, smallest+3, blobA.Size())
-	blobB := makeCacheBlob('b', 6) // 28 byts
+	blobB := makeCacheBlob('b', 6) // 35 bytes
 	assertEqual(t, smallest+6, blobB.Size())
-	blobC := makeCacheBlob('c', 6) // 28 bytes
+	blobC := makeCacheBlob('c', 6) // 35 bytes
 	assertEqual(t, smallest+6, blobC.Size())
 
 	qsize := blobA.Size() + blobB.Size() + blobC.Size() + 10
 	queue := newBytesQueue(qsize, 0, newNopLogger())
 
 	// when
-	queue.push(blobA) // 25 bytes
-	queue.push(blobB) // additional 28 bytes
-	queue.pop()       // space freed, 25 bytes available at the beginning
-	queue.push(blobC) // 28 bytes needed,   10 bytes available at the tail
+	queue.push(blobA) // 32 bytes
+	queue.push(blobB) // additional 35 bytes
+	queue.pop()       // space freed, 32 bytes available at the beginning
+	queue.push(blobC) // 35 bytes needed,   10 bytes available at the tail
 
 	// then
 	assertEqual(t, qsize, queue.cap())
@@ -191,29 +191,29 @@ func TestAllocateAdditionalSpaceForInsufficientFreeFragmentedSpaceWhereHeadIsBef
 func TestUnchangedEntriesIndexesAfterAdditionalMemoryAllocationWhereHeadIsBeforeTail(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
-	blobA := makeCacheBlob('a', 3) // 25 bytres
+	blobA := makeCacheBlob('a', 3) // 32 bytes
 	assertEqual(t, smallest+3, blobA.Size())
-	blobB := makeCacheBlob('b', 6) // 28 byts
+	blobB := makeCacheBlob('b', 6) // 35 bytes
 	assertEqual(t, smallest+6, blobB.Size())
-	blobC := makeCacheBlob('c', 6) // 28 bytes
+	blobC := makeCacheBlob('c', 6) // 35 bytes
 	assertEqual(t, smallest+6, blobC.Size())
-	blobD := makeCacheBlob('d', 6) // 28 bytes
+	blobD := makeCacheBlob('d', 6) // 35 bytes
 	assertEqual(t, smallest+6, blobD.Size())
 
 	qsize := blobA.Size() + blobB.Size() + blobC.Size() + 10
 	queue := newBytesQueue(qsize, 0, newNopLogger())
 
 	// when
-	queue.push(blobA)            // 25 bytes
-	refB, _ := queue.push(blobB) // additional 28 bytes
-	queue.pop()                  // space freed, 25 bytes available at the beginning
-	refC, _ := queue.push(blobC) // 28 bytes needed,   10 bytes available at the tail
+	queue.push(blobA)            // 32 bytes
+	refB, _ := queue.push(blobB) // additional 35 bytes
+	queue.pop()                  // space freed, 32 bytes available at the beginning
+	refC, _ := queue.push(blobC) // 35 bytes needed,   10 bytes available at the tail
 
 	// reallocation
-	queue.push(blobD) // another 28 bytes
+	queue.push(blobD) // another 35 bytes
 
 	// then
 	assertEqual(t, qsize*2, queue.cap())
@@ -228,7 +228,7 @@ func TestUnchangedEntriesIndexesAfterAdditionalMemoryAllocationWhereHeadIsBefore
 func TestAllocateAdditionalSpaceForInsufficientFreeFragmentedSpaceWhereTailIsBeforeHead(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
 	blobA := makeCacheBlob('a', 70)
@@ -244,11 +244,11 @@ func TestAllocateAdditionalSpaceForInsufficientFreeFragmentedSpaceWhereTailIsBef
 	queue := newBytesQueue(qsize, 0, newNopLogger())
 
 	// when
-	queue.push(blobA) // 92 bytes
-	queue.push(blobB) // 92 + 32 = 124 bytes
-	queue.pop()       // space freed at the beginning 92 bytes
-	queue.push(blobC) // 52 bytes used at the beginning, tail pointer is before head pointer
-	queue.push(blobD) // 62 bytes needed but no available in one segment, allocate new memory
+	queue.push(blobA) // 99 bytes
+	queue.push(blobB) // 99 + 39 = 138 bytes
+	queue.pop()       // space freed at the beginning 99 bytes
+	queue.push(blobC) // 59 bytes used at the beginning, tail pointer is before head pointer
+	queue.push(blobD) // 69 bytes needed but no available in one segment, allocate new memory
 
 	// then
 	assertEqual(t, qsize*2, queue.cap())
@@ -279,7 +279,7 @@ func TestAllocateAdditionalSpaceForInsufficientFreeFragmentedSpaceWhereTailIsBef
 func TestUnchangedEntriesIndexesAfterAdditionalMemoryAllocationWhereTailIsBeforeHead(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
 	blobA := makeCacheBlob('a', 70)
@@ -295,11 +295,11 @@ func TestUnchangedEntriesIndexesAfterAdditionalMemoryAllocationWhereTailIsBefore
 	queue := newBytesQueue(qsize, 0, newNopLogger())
 
 	// when
-	queue.push(blobA)            // 92 bytes
-	refB, _ := queue.push(blobB) // 92 + 32 = 124 bytes
-	queue.pop()                  // space freed at the beginning 92 bytes
-	queue.push(blobC)            // 52 bytes used at the beginning, tail pointer is before head pointer
-	refD, _ := queue.push(blobD) // 62 bytes needed but no available in one segment, allocate new memory
+	queue.push(blobA)            // 99 bytes
+	refB, _ := queue.push(blobB) // 99 + 39 = 138 bytes
+	queue.pop()                  // space freed at the beginning 99 bytes
+	queue.push(blobC)            // 59 bytes used at the beginning, tail pointer is before head pointer
+	refD, _ := queue.push(blobD) // 69 bytes needed but no available in one segment, allocate new memory
 
 	// then
 	assertEqual(t, qsize*2, queue.cap())
@@ -314,7 +314,7 @@ func TestUnchangedEntriesIndexesAfterAdditionalMemoryAllocationWhereTailIsBefore
 func TestAllocateAdditionalSpaceForValueBiggerThanInitQueue(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
 	queue := newBytesQueue(11, 0, newNopLogger())
@@ -328,14 +328,14 @@ func TestAllocateAdditionalSpaceForValueBiggerThanInitQueue(t *testing.T) {
 	noError(t, err1)
 	assertEqual(t, makeCacheBlob('a', 100), ce)
 
-	// 266 = (100 + 22 + 11) * 2
+	// 272 = (100 + 25 + 11) * 2
 	assertEqual(t, (smallest+11+100)*2, queue.cap())
 }
 
 func TestAllocateAdditionalSpaceForValueBiggerThanQueue(t *testing.T) {
 	t.Parallel()
 
-	smallest := (*CacheEntry).Size(nil) // 22
+	smallest := (*CacheEntry).Size(nil) // 29
 
 	// given
 	blobA := makeCacheBlob('a', 2)
@@ -358,7 +358,7 @@ func TestAllocateAdditionalSpaceForValueBiggerThanQueue(t *testing.T) {
 	noError(t, err)
 	noError(t, err1)
 	assertEqual(t, blobC, ce)
-	// 342 = (49 + 122) * 2
+	// 360 = (55 + 125) * 2
 	assertEqual(t, (qsize+smallest+100)*2, queue.cap())
 }
 
@@ -454,3 +454,197 @@ func TestMaxSizeLimit(t *testing.T) {
 	noError(t, err1)
 	assertEqual(t, blobB, ce)
 }
+
+func TestGrowLastEntryWhereHeadIsBeforeTail(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blobA := makeCacheBlob('a', 3)
+	blobB := makeCacheBlob('b', 6)
+	queue := newBytesQueue(100, 0, newNopLogger())
+	refA, _ := queue.push(blobA)
+	refB, _ := queue.push(blobB)
+	rest := 100 - blobA.Size() - blobB.Size()
+
+	// when
+	notLast := queue.grow(refA, 5)
+	grown := queue.grow(refB, rest) // exactly up to the end of array
+	full := queue.grow(refB, 1)
+
+	// then
+	assertEqual(t, false, notLast)
+	assertEqual(t, true, grown)
+	assertEqual(t, false, full)
+	assertEqual(t, 100, queue.cap())
+
+	ref, _ := queue.pop()
+	ce, _ := queue.get(ref)
+	assertEqual(t, blobA, ce)
+	ref, _ = queue.pop()
+	ce, err := queue.get(ref)
+	noError(t, err)
+	assertEqual(t, 6+rest, len(ce.Data))
+	assertEqual(t, blobB.Data, ce.Data[:6])
+
+	// when
+	empty := queue.grow(refB, 1)
+
+	// then
+	assertEqual(t, false, empty)
+	assertEqual(t, 0, queue.len())
+}
+
+func TestGrowLastEntryWhereTailIsBeforeHead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blobA := makeCacheBlob('a', 70)
+	blobB := makeCacheBlob('b', 10)
+	blobC := makeCacheBlob('c', 30)
+	qsize := blobA.Size() + blobB.Size() + 10
+	queue := newBytesQueue(qsize, 0, newNopLogger())
+	queue.push(blobA)
+	queue.push(blobB)
+	queue.pop()
+	refC, _ := queue.push(blobC) // tail wraps, 40 bytes left before head
+	limit := 40 - offKeyStr      // room for plug must be kept
+
+	// when
+	tooBig := queue.grow(refC, limit+1)
+	grown := queue.grow(refC, limit)
+
+	// then
+	assertEqual(t, false, tooBig)
+	assertEqual(t, true, grown)
+	assertEqual(t, qsize, queue.cap())
+
+	ref, _ := queue.pop()
+	ce, _ := queue.get(ref)
+	assertEqual(t, blobB, ce)
+	ref, _ = queue.pop()
+	ce, err := queue.get(ref)
+	noError(t, err)
+	assertEqual(t, refC, ref)
+	assertEqual(t, 30+limit, len(ce.Data))
+	assertEqual(t, 0, queue.len())
+}
+
+func TestQueueHeadroom(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blobA := makeCacheBlob('a', 70)
+	blobB := makeCacheBlob('b', 10)
+	blobC := makeCacheBlob('c', 30)
+	qsize := blobA.Size() + blobB.Size() + 10
+	queue := newBytesQueue(qsize, 0, newNopLogger())
+
+	// when
+	empty := queue.headroom()
+	queue.push(blobA)
+	queue.push(blobB)
+	full := queue.headroom()
+	queue.pop()
+	freed := queue.headroom()
+	queue.push(blobC) // tail wraps
+	wrapped := queue.headroom()
+
+	// then
+	assertEqual(t, qsize, empty)
+	assertEqual(t, 10, full)
+	assertEqual(t, 10+blobA.Size()-offKeyStr, freed) // room for plug is kept before head
+	assertEqual(t, blobA.Size()-blobC.Size()-offKeyStr, wrapped)
+	assertEqual(t, qsize, queue.cap())
+}
+
+func TestPushExactlyFillingSpaceAfterTail(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blob := makeCacheBlob('a', 11)
+	queue := newBytesQueue(3*blob.Size(), 0, newNopLogger())
+
+	// when
+	for i := 0; i < 3; i++ {
+		_, err := queue.push(blob)
+		noError(t, err)
+	}
+
+	// then
+	assertEqual(t, 3*blob.Size(), queue.cap())
+	assertEqual(t, int64(0), queue.expansions)
+	assertEqual(t, queue.cap(), queue.tail.idx())
+}
+
+func TestPushExactlyFillingSpaceBeforeHead(t *testing.T) {
+	t.Parallel()
+
+	// given - entry wrapped before head has to leave room for header, so gap could be plugged on expand
+	blob := makeCacheBlob('a', 11)
+	queue := newBytesQueue(3*blob.Size(), 0, newNopLogger())
+	for i := 0; i < 3; i++ {
+		queue.push(blob)
+	}
+	queue.pop()
+	queue.pop()
+	wrapped := makeCacheBlob('b', 2*blob.Size()-2*offKeyStr)
+
+	// when
+	ref, err := queue.push(wrapped)
+
+	// then
+	noError(t, err)
+	assertEqual(t, qref(0), ref)
+	assertEqual(t, int64(0), queue.expansions)
+	assertEqual(t, offKeyStr, queue.head.sub(queue.tail))
+
+	// when - no room left before head
+	_, err = queue.push(makeCacheBlob('c', 0))
+
+	// then
+	noError(t, err)
+	assertEqual(t, int64(1), queue.expansions)
+	assertEqual(t, 4, queue.len()) // gap before head is plugged with empty entry
+}
+
+func TestPushExactlyFillingGapBetweenTailAndHead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blob := makeCacheBlob('a', 11)
+	queue := newBytesQueue(4*blob.Size(), 0, newNopLogger())
+	for i := 0; i < 4; i++ {
+		queue.push(blob)
+	}
+	for i := 0; i < 3; i++ {
+		queue.pop()
+	}
+	queue.push(blob) // wraps, tail is before head now
+	gap := makeCacheBlob('b', queue.head.sub(queue.tail)-2*offKeyStr)
+
+	// when
+	_, err := queue.push(gap)
+
+	// then
+	noError(t, err)
+	assertEqual(t, int64(0), queue.expansions)
+	assertEqual(t, offKeyStr, queue.head.sub(queue.tail))
+}
+
+func TestExpandExactlyToMaxCapacity(t *testing.T) {
+	t.Parallel()
+
+	// given
+	blob := makeCacheBlob('a', 11)
+	queue := newBytesQueue(blob.Size(), 2*blob.Size(), newNopLogger())
+	queue.push(blob)
+
+	// when
+	_, err := queue.push(blob)
+
+	// then
+	noError(t, err)
+	assertEqual(t, 2*blob.Size(), queue.cap())
+	assertEqual(t, true, queue.fits(2*blob.Size()))
+	assertEqual(t, false, queue.fits(2*blob.Size()+1))
+}
@@ -0,0 +1,126 @@
+package bigcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrCipherOpen = errors.New("unable to decrypt entry")
+
+// Cipher encrypts entries data before it is written into shard queue and decrypts it on read, so values are never
+// kept in memory, memory mapped files, snapshots or write-ahead log in plain text. Keys are not encrypted, but they
+// are authenticated along with data, so value sealed for one key cannot be read under another one. Entries stored
+// with already hashed keys have no key to authenticate.
+// Implementation must be safe for concurrent use.
+type Cipher interface {
+	// NonceSize returns length of nonce every sealed value gets, it is kept in the entry header. Nonce could not be
+	// longer than 255 bytes.
+	NonceSize() int
+	// Seal fills nonce with a fresh value, appends plaintext encrypted with it to dst and returns resulting slice.
+	// Additional data is authenticated, but not encrypted.
+	Seal(dst, nonce, plaintext, additionalData []byte) ([]byte, error)
+	// Open appends decrypted ciphertext produced by Seal with the same nonce to dst and returns resulting slice.
+	// It fails when additional data differs from the one passed to Seal.
+	Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// aesGCM is Cipher using AES in Galois/Counter Mode with random nonces.
+type aesGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher returns Cipher using AES-GCM with given key, which must be 16, 24 or 32 bytes long to select
+// AES-128, AES-192 or AES-256. Every entry gets random nonce kept in its header along with authentication tag, so
+// encryption adds 29 bytes to the size of every entry.
+// Random nonces are safe for up to about 2^32 writes with the same key, key should be rotated before that.
+func NewAESGCMCipher(key []byte) (Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCM{aead: aead}, nil
+}
+
+func (c *aesGCM) NonceSize() int {
+	return c.aead.NonceSize()
+}
+
+func (c *aesGCM) Seal(dst, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+func (c *aesGCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.aead.NonceSize() {
+		return nil, ErrCipherOpen
+	}
+	data, err := c.aead.Open(dst, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCipherOpen, err)
+	}
+	return data, nil
+}
+
+// seal encrypts entry data for its key when cache uses cipher, nonce is kept in entry header. Nil entries are kept
+// as they are, they do not carry any data.
+func (s *cacheShard) seal(ce *CacheEntry) error {
+	if s.cipher == nil || ce.Data == nil {
+		return nil
+	}
+	nonce := make([]byte, s.cipher.NonceSize())
+	data, err := s.cipher.Seal(nil, nonce, ce.Data, ce.Key)
+	if err != nil {
+		return err
+	}
+	ce.Data, ce.nonce, ce.flags = data, nonce, ce.flags|flagSealed
+	return nil
+}
+
+// plain returns entry data, decrypted when cache uses cipher.
+// NOTE: without cipher returned slice references shard's buffer.
+func (s *cacheShard) plain(r qref) ([]byte, error) {
+	buf := s.entries.array
+	data, flags := r.data(buf), r.flags(buf)
+	if s.cipher == nil || flags&(flagNil|flagNegative) != 0 {
+		return data, nil
+	}
+	if flags&flagSealed == 0 {
+		return nil, ErrCipherOpen
+	}
+	// empty data is opened into empty slice, not nil
+	return s.cipher.Open([]byte{}, r.nonce(buf), data, r.key(buf))
+}
+
+// dataCopy returns copy of entry data safe to use outside of shard lock.
+func (s *cacheShard) dataCopy(r qref) ([]byte, error) {
+	if s.cipher == nil {
+		return s.entries.getDataCopy(r), nil
+	}
+	if s.entries.getFlags(r)&flagNil != 0 {
+		return nil, nil
+	}
+	return s.plain(r)
+}
+
+// entry reads entry with data decrypted when cache uses cipher.
+// NOTE: without cipher entry references shard's buffer.
+func (s *cacheShard) entry(r qref) (*CacheEntry, error) {
+	ce, err := s.entries.get(r)
+	if err != nil || s.cipher == nil || ce.Data == nil {
+		return ce, err
+	}
+	if ce.Data, err = s.plain(r); err != nil {
+		return nil, err
+	}
+	ce.nonce, ce.flags = nil, ce.flags&^flagSealed
+	return ce, nil
+}
@@ -0,0 +1,172 @@
+package bigcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func cipherConfig(t *testing.T) Config {
+	c, err := NewAESGCMCipher(bytes.Repeat([]byte{1}, 32))
+	noError(t, err)
+	return Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Cipher:             c,
+	}
+}
+
+func TestCipherEncryptsData(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+
+	// when
+	cache.Set("key", []byte("secret value"))
+	cache.Append("key", []byte("!"))
+	cache.Set("empty", []byte{})
+	cache.Set("nil", nil)
+	value, err := cache.Get("key")
+	empty, _ := cache.Get("empty")
+	nilValue, _ := cache.Get("nil")
+	var processed []byte
+	cache.GetWithProcessing("key", func(ce *CacheEntry) error {
+		processed = ce.CopyData(0)
+		return nil
+	})
+	dst := make([]byte, 64)
+	n, intoErr := cache.GetInto("key", dst)
+
+	// then
+	noError(t, err)
+	noError(t, intoErr)
+	assertEqual(t, []byte("secret value!"), value)
+	assertEqual(t, []byte{}, empty)
+	assertEqual(t, []byte(nil), nilValue)
+	assertEqual(t, value, processed)
+	assertEqual(t, value, dst[:n])
+	shard := cache.shards[0]
+	assertEqual(t, false, bytes.Contains(shard.entries.array, []byte("secret")))
+}
+
+func TestCipherWithProcessingAndRange(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+
+	// when
+	err := cache.SetWithProcessing("key", 6, func(dst []byte) error {
+		copy(dst, "secret")
+		return nil
+	})
+	var ranged []byte
+	cache.Range(func(ce *CacheEntry) error {
+		ranged = ce.Data
+		return nil
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("secret"), ranged)
+	assertEqual(t, false, bytes.Contains(cache.shards[0].entries.array, []byte("secret")))
+}
+
+func TestCipherDetectsWrongKey(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+	cache.Set("key", []byte("value"))
+	var buf bytes.Buffer
+	noError(t, cache.Save(&buf))
+	other, _ := NewAESGCMCipher(bytes.Repeat([]byte{2}, 32))
+	config := cipherConfig(t)
+	config.Cipher = other
+	loaded, _ := NewBigCache(config)
+
+	// when
+	noError(t, loaded.Load(&buf))
+	_, err := loaded.Get("key")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrCipherOpen))
+}
+
+func TestCipherDetectsValuesSwappedBetweenKeys(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+	cache.Set("key1", []byte("value1"))
+	cache.Set("key2", []byte("value2"))
+	shard := cache.shards[0]
+	ref1, _ := shard.indexGet(cache.hash.Sum64("key1"))
+	ref2, _ := shard.indexGet(cache.hash.Sum64("key2"))
+
+	// when
+	copy(ref1.data(shard.entries.array), ref2.data(shard.entries.array))
+	_, err := cache.Get("key1")
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrCipherOpen))
+	value, err := cache.Get("key2")
+	noError(t, err)
+	assertEqual(t, []byte("value2"), value)
+}
+
+func TestCipherKeepsNonceInHeader(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Set("other", []byte("value"))
+
+	// then
+	shard := cache.shards[0]
+	buf := shard.entries.array
+	ref, _ := shard.indexGet(cache.hash.Sum64("key"))
+	other, _ := shard.indexGet(cache.hash.Sum64("other"))
+	assertEqual(t, flagSealed, ref.flags(buf)&flagSealed)
+	assertEqual(t, 12, len(ref.nonce(buf)))
+	assertEqual(t, false, bytes.Equal(ref.nonce(buf), other.nonce(buf)))
+	assertEqual(t, []byte("key"), ref.key(buf))
+	assertEqual(t, len("value")+16, len(ref.data(buf)))
+	ce, err := shard.entries.get(ref)
+	noError(t, err)
+	marshaled, _ := ce.MarshalBinary()
+	var decoded CacheEntry
+	noError(t, decoded.UnmarshalBinary(marshaled))
+	assertEqual(t, ref.nonce(buf), decoded.nonce)
+	assertEqual(t, ref.data(buf), decoded.Data)
+}
+
+// longNonceCipher has nonce which does not fit into entry header.
+type longNonceCipher struct {
+	Cipher
+}
+
+func (longNonceCipher) NonceSize() int {
+	return maxNonceLength + 1
+}
+
+func TestCipherNonceMustFitIntoHeader(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := cipherConfig(t)
+	config.Cipher = longNonceCipher{config.Cipher}
+
+	// when
+	_, err := NewBigCache(config)
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrHeaderField))
+}
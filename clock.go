@@ -2,6 +2,11 @@ package bigcache
 
 import "time"
 
+// Clock provides current time in seconds, it is used to timestamp and expire entries.
+type Clock interface {
+	Epoch() uint64
+}
+
 type clock interface {
 	epoch() uint64
 }
@@ -11,3 +16,31 @@ type systemClock struct{}
 func (c systemClock) epoch() uint64 {
 	return uint64(time.Now().Unix())
 }
+
+// monotonicClock counts time since its creation using monotonic clock reading, so it is not affected by wall
+// clock adjustments. Values start from wall clock time of creation to stay comparable with Unix time.
+type monotonicClock struct {
+	start time.Time
+	base  uint64
+}
+
+// NewMonotonicClock returns Clock which is immune to wall clock jumps (i.e. NTP adjustments), which otherwise could
+// make entries expire prematurely or stay in cache for too long. To be used as Config.Clock.
+// NOTE: in the long run its time could drift from wall clock time.
+func NewMonotonicClock() Clock {
+	now := time.Now()
+	return &monotonicClock{start: now, base: uint64(now.Unix())}
+}
+
+func (c *monotonicClock) Epoch() uint64 {
+	return c.base + uint64(time.Since(c.start)/time.Second)
+}
+
+// userClock adapts Clock provided in Config.
+type userClock struct {
+	Clock
+}
+
+func (c userClock) epoch() uint64 {
+	return c.Epoch()
+}
@@ -0,0 +1,57 @@
+package bigcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type manualClock struct {
+	value uint64
+}
+
+func (c *manualClock) Epoch() uint64 {
+	return c.value
+}
+
+func TestMonotonicClock(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := NewMonotonicClock()
+	now := uint64(time.Now().Unix())
+
+	// when
+	first := clock.Epoch()
+	clock.(*monotonicClock).start = clock.(*monotonicClock).start.Add(-time.Hour)
+	second := clock.Epoch()
+
+	// then
+	assertEqual(t, true, first >= now && first <= now+1)
+	assertEqual(t, first+3600, second)
+}
+
+func TestConfigClockIsUsed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &manualClock{value: 100}
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Clock:              clock,
+	})
+	cache.Set("key", []byte("value"))
+
+	// when
+	oldest, _ := cache.GetOldest()
+	clock.value = 105
+	cache.cleanUp(cache.clock.epoch())
+	_, err := cache.Get("key")
+
+	// then
+	assertEqual(t, uint64(100), oldest.TS)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
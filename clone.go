@@ -0,0 +1,33 @@
+package bigcache
+
+// Clone creates independent cache with the same configuration and copies of all live entries, which keep their keys,
+// values and timestamps. Statistics and subscriptions are not copied. Source cache could be used while it is being
+// cloned, every shard is read locked only while its entries are copied.
+// Clone does not use write-ahead log and snapshot of the source cache and is not warmed up.
+// NOTE: clone starts its own background goroutines, it should be closed when no longer needed.
+func (c *BigCache) Clone() (*BigCache, error) {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	config := c.shardConfig()
+	// clone gets entries of the source only, warmup entries are there already unless they were replaced
+	config.WALPath, config.SnapshotPath, config.Warmup = "", "", nil
+	clone, err := newBigCache(config, c.clock)
+	if err != nil {
+		return nil, err
+	}
+	// both caches have identical layout, so entries stay in shards with the same index
+	for i, shard := range c.shards {
+		shard.RLock()
+		shard.live(func(r qref) bool {
+			ce, err := shard.entries.get(r)
+			if err != nil {
+				return true
+			}
+			_ = clone.shards[i].pushWithoutLock(ce)
+			return true
+		})
+		shard.RUnlock()
+	}
+	return clone, nil
+}
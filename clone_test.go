@@ -0,0 +1,103 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCloneCopiesEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             16,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 1000,
+		MaxEntrySize:       32,
+	}, &clock)
+	keys := 1000
+	for i := 0; i < keys; i++ {
+		clock.set(uint64(i / 100))
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	cache.Delete("key0")
+
+	// when
+	clone, err := cache.Clone()
+
+	// then
+	noError(t, err)
+	defer clone.Close()
+	assertEqual(t, cache.Len(), clone.Len())
+	for i := 1; i < keys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		value, err := clone.Get(key)
+		noError(t, err)
+		assertEqual(t, []byte(fmt.Sprintf("value%d", i)), value)
+		clone.GetWithProcessing(key, func(ce *CacheEntry) error {
+			assertEqual(t, uint64(i/100), ce.TS)
+			return nil
+		})
+	}
+	_, err = clone.Get("key0")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("value"))
+	clone, _ := cache.Clone()
+	defer clone.Close()
+
+	// when
+	clone.Set("key", []byte("changed"))
+	clone.Set("new", []byte("value"))
+	cache.Delete("key")
+
+	// then
+	_, err := cache.Get("key")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	_, err = cache.Get("new")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	value, err := clone.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte("changed"), value)
+	assertEqual(t, 0, cache.Len())
+	assertEqual(t, 2, clone.Len())
+}
+
+func TestCloneIsNotWarmedUpAgain(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Warmup:             map[string][]byte{"key": []byte("warm"), "other": []byte("warm")},
+	})
+	defer cache.Close()
+	noError(t, cache.Set("key", []byte("overwritten")))
+	noError(t, cache.Delete("other"))
+
+	// when
+	clone, err := cache.Clone()
+
+	// then
+	noError(t, err)
+	defer clone.Close()
+	value, err := clone.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte("overwritten"), value)
+	_, err = clone.Get("other")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, 1, clone.Len())
+	assertEqual(t, true, clone.Verify() == nil)
+}
@@ -1,6 +1,9 @@
 package bigcache
 
-import "time"
+import (
+	"runtime"
+	"time"
+)
 
 // RemoveReason is a value used to signal to the user why a particular key was removed in the OnRemove callback.
 type RemoveReason int
@@ -11,14 +14,19 @@ const (
 	NoSpace               // key is the oldest and the cache size was at its maximum when Set() was called, or entry size exceeded the maximum shard size.
 	Deleted               // key was removed as a result of Delete() call
 
-	minimumEntriesInShard = 10 // Minimum number of entries in single shard
+	minimumEntriesInShard = 10 // Default minimum number of entries in single shard
+	autoShardsPerProc     = 16 // Number of shards per GOMAXPROCS when number of shards is selected automatically
 )
 
 type OnRemoveCallback func(*CacheEntry, RemoveReason)
 
 // Config for BigCache.
 type Config struct {
-	// Number of cache shards, value must be a power of two
+	// Number of cache shards, value must be a power of two.
+	// When set to 0 it is selected automatically as the next power of two >= 16 * GOMAXPROCS, which keeps lock
+	// contention low without wasting memory on small machines. Shard is the unit of locking: writes lock the whole
+	// shard as they may move or evict any of its entries, so when writers delay readers increase number of shards,
+	// when readers contend with each other see ReadLockStripes.
 	Shards int
 	// Time after which entry can be evicted
 	LifeWindow time.Duration
@@ -30,19 +38,180 @@ type Config struct {
 	MaxEntriesInWindow int
 	// Max size of entry in bytes. Used only to calculate initial size for cache shards.
 	MaxEntrySize int
+	// MinEntriesPerShard is a minimum number of entries shard is initially sized for, regardless of
+	// MaxEntriesInWindow. Lower it for caches keeping few large entries per shard. Value must not be negative,
+	// default value 0 means 10.
+	MinEntriesPerShard int
+	// InitialShardCapacityBytes is initial size of shard's queue in bytes. When set to > 0 it takes precedence
+	// over size derived from MaxEntriesInWindow and MaxEntrySize, it is still limited by HardMaxCacheSize.
+	InitialShardCapacityBytes int
+	// MaxKeyLength is a maximum length of key in bytes, longer keys are rejected by Set with ErrKeyTooLong.
+	// Keys are stored with entries, so they could not be longer than 65535 bytes, which is the default value.
+	MaxKeyLength int
+	// KeyValidator is called with key before every keyed operation, i.e. Get, Set, Append or Delete, which fails
+	// with its error when it returns one. It allows to enforce key conventions in one place. Methods using already
+	// hashed keys bypass it. Default value is nil which means keys are not validated.
+	KeyValidator func(key string) error
 	// Hasher used to map between string keys and unsigned 64bit integers, by default fnv64 hashing is used.
 	Hasher Hasher
+	// HashSeed is mixed into default hasher, so instances using different seeds map keys to different hashes and
+	// shards. Picking a random one makes it harder to craft keys colliding in a single shard when keys come from
+	// untrusted input. It cannot be used with custom Hasher and must not change between runs restoring snapshot or
+	// write-ahead log. Default value is 0 which means keys are hashed with standard FNV-1a.
+	HashSeed uint64
+	// NewIndex creates index mapping entry hashes to their positions for a shard, capacity is expected number of
+	// entries. It is called for every shard when cache is created, reset or resized. It allows to use index with
+	// lower memory overhead than built-in map for very large caches, see Index.
+	// Default value is nil which means built-in map is used.
+	NewIndex func(capacity int) Index
+	// ShardSelector maps hashed keys to shards. By default the lowest bits of the hash are used, which is the
+	// fastest option. See NewConsistentShardSelector for alternative which minimizes keys movement on resizing.
+	ShardSelector ShardSelector
 	// HardMaxCacheSize is a limit for cache size in MB. Cache will not allocate more memory than this limit.
 	// It can protect application from consuming all available memory on machine, therefore from running OOM Killer.
 	// Default value is 0 which means unlimited size. When the limit is higher than 0 and reached then
 	// the oldest entries are overridden for the new ones.
 	HardMaxCacheSize int
+	// GlobalMaxEntries is a limit for number of entries in the whole cache. Unlike HardMaxCacheSize it is not split
+	// evenly between shards, so skewed keys distribution does not make busy shard evict entries while others are
+	// mostly empty. When the limit is reached the oldest entries of the shard new entry is stored to are evicted.
+	// Limit could be exceeded slightly when that shard has no other entries.
+	// Default value is 0 which means unlimited number of entries.
+	GlobalMaxEntries int
+	// Storage allocates memory for shard queues, see NewMmapStorage. Memory is released by Close.
+	// Default value is nil which means queues are allocated on Go heap.
+	Storage Storage
+	// PreallocateToMax makes every shard allocate its maximum size (HardMaxCacheSize divided by number of shards)
+	// up front, so shard queues are never reallocated at runtime. This trades memory for predictable latency.
+	// It requires HardMaxCacheSize to be set.
+	PreallocateToMax bool
 	// OnRemove is a callback fired when the entry is removed because of its expiration time or no space left
 	// for the new entry, or because delete was called.
 	// Default value is nil which means no callback
 	OnRemove OnRemoveCallback
+	// OnExpired, OnEvicted and OnDeleted are callbacks fired only when the entry is removed for their reason: because
+	// of its expiration time (Expired), no space left (NoSpace) or delete call (Deleted) respectively. Entry is
+	// read only for reasons having callbacks, so they are cheaper than OnRemove which checks reason itself.
+	// When both OnRemove and callback for the reason are set OnRemove is called first, then the specific one.
+	// Default value is nil which means no callback
+	OnExpired func(*CacheEntry)
+	OnEvicted func(*CacheEntry)
+	OnDeleted func(*CacheEntry)
+	// OnBeforeEvict is called with the oldest entry before it is evicted because it expired (Expired) or there is
+	// no space for new one (NoSpace), while entry is still intact, i.e. to persist it. Expired entry for which it
+	// returns true is kept and stored again as if it was just set. Answer is ignored for NoSpace, as keeping
+	// entries would prevent cache from making room for new ones. Entries removed by IdleTimeout are not reported.
+	// Like other callbacks it is called under shard lock and entry references shard's buffer.
+	// Default value is nil which means entries are always evicted.
+	OnBeforeEvict func(*CacheEntry, RemoveReason) (keep bool)
+	// OnShardFull is called with entry which could not be stored because its shard cannot make room for it even
+	// after evicting older entries, i.e. when HardMaxCacheSize is too small, along with the index of the shard.
+	// Write still fails with ErrQueueFull or ErrQueueEntryTooBig. Entry data is nil when it is being written by
+	// SetWithProcessing. Callback is called under shard lock, so it must not access the cache.
+	// Default value is nil which means failure is only reported by returned error.
+	OnShardFull func(entry *CacheEntry, shard int)
 	// Logger is a logging interface. Defaults to `NopLogger()`
 	Logger Logger
+	// CollisionLogRate is a maximum number of collision messages logged per second by a single shard, number of
+	// messages skipped is reported with the next one logged. Negative value disables collision logging.
+	// Collisions are always counted in Stats. Default value is 0 which means every collision is logged.
+	CollisionLogRate int
+	// LogAllocationsAbove is a shard queue capacity in bytes, growing queue beyond it is logged with debug severity.
+	// Default value is 0 which means queue allocations are not logged.
+	LogAllocationsAbove int
+	// HotKeysPerShard is a number of the most read hashes every shard counts hits of for HotKeys(). Counting costs
+	// an additional lock on every hit. Default value is 0 which means hits are not tracked.
+	HotKeysPerShard int
+	// UniqueMembers makes AddToSet skip values which are already members of the set kept under the key, which
+	// costs a scan of the set. Default value is false which means values are always added, so set is a multiset.
+	UniqueMembers bool
+	// EventBufferSize is a size of buffered channel created for every Subscribe() call. Events which do not fit
+	// into the buffer are dropped rather than blocking cache operations. Default value is 1024.
+	EventBufferSize int
+	// NegativeTTL is a time during which marker stored by SetMiss() makes Get() return ErrEntryNegativeCached
+	// for the key. After that key is reported as not found until marker is evicted or replaced.
+	NegativeTTL time.Duration
+	// BloomFilterSize is a number of counters in per shard counting Bloom filter which lets Get() to report
+	// definitely absent keys without taking shard lock. Filter may give false positives, in which case regular
+	// lookup is performed, so it should be sized well above expected number of entries in a shard to be effective
+	// (see Stats.BloomFillRatio). Each counter takes 4 bytes. Default value is 0 which means no filter.
+	BloomFilterSize int
+	// EvictionWatermark is a part of HardMaxCacheSize shard is evicted down to when it runs out of space.
+	// Evicting more than a single entry at once amortizes eviction cost for writes near the limit.
+	// Value must be in (0, 1] range, default value 0 (same as 1) evicts only as much as necessary to fit new entry.
+	EvictionWatermark float64
+	// IdleTimeout is a time after which entry which was not read is evicted, regardless of LifeWindow, which suits
+	// session-like data. Reads (Get and friends, not Peek) reset idle time, they take shard write lock then, so
+	// concurrent reads of the same shard are serialized. Idle entries are evicted with Expired reason by background
+	// cleanup, so CleanWindow must be set too. It needs full entry header profile, see bigcache_full_header build tag.
+	// Default value is 0 which means entries never become idle.
+	IdleTimeout time.Duration
+	// ReclaimInterval is an interval of background runs reclaiming space of deleted entries which reached head of
+	// shard's queue, otherwise it is reclaimed only when shard runs out of space or entries behind them expire.
+	// Every run handles a few entries per shard, so it holds shard locks briefly. Reclaimed entries are counted in
+	// Stats.ReclaimedTombstones. Default value is 0 which means no background reclaiming.
+	ReclaimInterval time.Duration
+	// MaxEvictionScan is a maximum number of entries, including deleted ones, single write evicts or skips to make
+	// room for new entry. Write fails with ErrQueueFull when limit is reached, entries removed by it are not restored,
+	// so retry continues from where it stopped. It bounds latency of writes into shards full of deleted entries.
+	// Default value is 0 which means unlimited.
+	MaxEvictionScan int
+	// CleanupBatchSize is a maximum number of expired entries removed by cleanup while shard lock is held. Lock is
+	// released between batches, so operations waiting for it are not delayed when many entries expire at once.
+	// With IdleTimeout it also limits number of entries checked for idleness under single lock.
+	// Default value is 0 which means all expired entries of a shard are removed under single lock.
+	CleanupBatchSize int
+	// ReadLockStripes is a number of read locks every shard is striped into. Readers of entries lock only the stripe
+	// selected by hash of the key, so they do not contend on single lock, while writers lock all stripes, which
+	// makes writes slower. It pays off for read-mostly loads on many cores. Reads lock whole shard anyway when
+	// IdleTimeout is set. Default value is 0 which means single read-write lock per shard.
+	ReadLockStripes int
+	// EvictionPolicy selects entries evicted when shard runs out of space. Expired entries are always removed
+	// in the order they were written. Default value is FIFO.
+	EvictionPolicy EvictionPolicy
+	// MemoryPressureHandler enables watchdog evicting entries when process memory usage crosses threshold.
+	// Default value is nil which means no watchdog.
+	MemoryPressureHandler *MemoryPressureHandler
+	// RefreshAhead enables asynchronous refresh of entries read by Get shortly before they expire.
+	// Default value is nil which means entries are never refreshed.
+	RefreshAhead *RefreshAhead
+	// WALPath is a path of write-ahead log file. When set every Set and Delete is also appended to the log and
+	// cache is restored from it on creation, so contents which are expensive to rebuild survive restarts. Entries
+	// keep their timestamps, so the ones expired in the meantime are removed as usual. Evictions are not logged.
+	// Hasher must not change between runs. Default value is empty which means no log.
+	WALPath string
+	// WALSync tells when log is flushed to stable storage, see WALSyncPolicy. Default value is WALSyncInterval.
+	WALSync WALSyncPolicy
+	// WALSyncInterval is an interval of flushes for WALSyncInterval policy. Default value is 1 second.
+	WALSyncInterval time.Duration
+	// WALCompactInterval is an interval of log compaction, see CompactWAL. Log grows with every write until it is
+	// compacted. Default value is 0 which means log is compacted only when CompactWAL is called.
+	WALCompactInterval time.Duration
+	// Warmup is a set of entries stored into the cache right after it is created, as if Set was called for every one.
+	// They are stored before snapshot and write-ahead log are loaded, so entries restored from those take precedence.
+	// Entries which cannot be stored, i.e. too big ones, are logged and skipped.
+	// Default value is nil which means cache starts empty.
+	Warmup map[string][]byte
+	// SnapshotPath is a path of snapshot file. When set cache is restored from it on creation and saved to it when
+	// closed, see Save and Load. Snapshot is written to temporary file renamed over the previous one, so it is never
+	// left partially written. Default value is empty which means no snapshot.
+	SnapshotPath string
+	// SnapshotInterval is an interval of background snapshots, changes made since the last one are lost on crash.
+	// Default value is 0 which means snapshot is saved only on Close.
+	SnapshotInterval time.Duration
+	// Cipher encrypts entries data kept in the cache, see NewAESGCMCipher. Processor passed to WithProcessing
+	// functions gets decrypted copy of data and SetWithProcessing fills temporary buffer, so they lose their
+	// zero-copy advantage. Default value is nil which means data is not encrypted.
+	Cipher Cipher
+	// DisableStats turns off collection of statistics, which saves atomic counter updates on every operation.
+	// Counters returned by Stats() are zero then.
+	DisableStats bool
+	// Clock is a source of time for entries timestamps and expiration, see NewMonotonicClock.
+	// Default value is nil which means wall clock time is used.
+	Clock Clock
+	// Tracer is used by GetContext() and SetContext() to record cache operations.
+	// Default value is nil which means no tracing and no overhead.
+	Tracer Tracer
 }
 
 // DefaultConfig initializes config with sane default values.
@@ -60,9 +229,34 @@ func DefaultConfig(eviction time.Duration) Config {
 	}
 }
 
+// autoShards computes number of shards for current hardware.
+func autoShards() int {
+	return nextPowerOfTwo(runtime.GOMAXPROCS(0) * autoShardsPerProc)
+}
+
 // initialShardSize computes initial shard size.
 func (c Config) initialShardSize() int {
-	return max(c.MaxEntriesInWindow/c.Shards, minimumEntriesInShard)
+	minimum := c.MinEntriesPerShard
+	if minimum <= 0 {
+		minimum = minimumEntriesInShard
+	}
+	return max(c.MaxEntriesInWindow/c.Shards, minimum)
+}
+
+// evictionWatermarkInBytes computes shard size to evict down to when shard is full, 0 means no watermark.
+func (c Config) evictionWatermarkInBytes() int {
+	if c.EvictionWatermark <= 0 || c.EvictionWatermark >= 1 {
+		return 0
+	}
+	return int(c.EvictionWatermark * float64(c.maximumShardSizeInBytes()))
+}
+
+// maxKeyLength returns maximum length of key stored in the cache.
+func (c Config) maxKeyLength() int {
+	if c.MaxKeyLength <= 0 || c.MaxKeyLength > maxKeyLength {
+		return maxKeyLength
+	}
+	return c.MaxKeyLength
 }
 
 // maximumShardSizeInBytes computes maximum shard size in bytes
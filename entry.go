@@ -1,25 +1,52 @@
 package bigcache
 
 import (
+	"encoding"
 	"encoding/binary"
 	"errors"
+	"io"
 )
 
 var (
 	ErrCacheEntryCorrupted = errors.New("cache entry is corrupted, unable to read")
 )
 
+// Header starts with fields every entry has, optional fields follow them. Header profile selected at compile time
+// decides which optional fields are present (see header_full.go and header_compact.go), their sizes are 0 otherwise.
+// Header of entry encrypted by Config.Cipher ends with nonce, prefixed by its length, before the key starts.
 const (
 	sizeLen    = 4 // Number of bytes to be used for full length of serialized cache entry
 	sizeTS     = 8 // Number of bytes used for timestamp
 	sizeHash   = 8 // Number of bytes used for hash
 	sizeKeyLen = 2 // Number of bytes used for size of entry key
+	sizeFlags  = 1 // Number of bytes used for entry flags
+	sizeNonce  = 1 // Number of bytes used for length of nonce of encrypted entry
 
 	offLen    = 0
 	offTS     = offLen + sizeLen
 	offHash   = offTS + sizeTS
 	offKeyLen = offHash + sizeHash
-	offKeyStr = offKeyLen + sizeKeyLen
+	offFlags  = offKeyLen + sizeKeyLen
+	offTag    = offFlags + sizeFlags
+	offAccess = offTag + sizeTag
+	offKeyStr = offAccess + sizeAccess
+
+	maxKeyLength   = 1<<(8*sizeKeyLen) - 1 // Longest key which length fits into the header
+	maxNonceLength = 1<<(8*sizeNonce) - 1  // Longest nonce which length fits into the header
+
+	headerHasTag    = sizeTag > 0    // entries keep user tags
+	headerHasAccess = sizeAccess > 0 // entries keep time of the last access
+)
+
+// entryFlags keeps internal entry markers, it is stored in the entry header.
+type entryFlags uint8
+
+const (
+	flagNegative    entryFlags = 1 << iota // entry is a marker of known to be absent key, it has no data
+	flagNil                                // entry was stored with nil data, which is returned as nil rather than empty slice
+	flagDeleted                            // entry is a deletion record of write-ahead log, it is never stored in the queue
+	flagOverwritten                        // deleted entry was replaced by the newer one for the same key
+	flagSealed                             // entry data is encrypted, header keeps nonce it was sealed with
 )
 
 type qref int
@@ -72,38 +99,88 @@ func (r qref) hash(buf []byte) uint64 {
 	return binary.LittleEndian.Uint64(buf[r+offHash:])
 }
 
+func (r qref) flags(buf []byte) entryFlags {
+	return entryFlags(buf[int(r)+offFlags])
+}
+
+// Returns position of the key, which follows nonce of encrypted entries.
+func (r qref) keyStr(buf []byte) int {
+	if r.flags(buf)&flagSealed == 0 {
+		return int(r) + offKeyStr
+	}
+	return int(r) + offKeyStr + sizeNonce + int(buf[int(r)+offKeyStr])
+}
+
+func (r qref) nonce(buf []byte) []byte {
+	if r.flags(buf)&flagSealed == 0 {
+		return nil
+	}
+	off := int(r) + offKeyStr + sizeNonce
+	return buf[off : off+int(buf[off-sizeNonce])]
+}
+
+// Checks that nonce of encrypted entry, read from possibly corrupted buffer, leaves room for the key.
+func (r qref) validNonce(buf []byte) bool {
+	if r.flags(buf)&flagSealed == 0 {
+		return true
+	}
+	l := r.size(buf)
+	return l > offKeyStr && r.keyStr(buf)+int(binary.LittleEndian.Uint16(buf[r+offKeyLen:])) <= int(r)+l
+}
+
 func (r qref) key(buf []byte) []byte {
-	kl := int(binary.LittleEndian.Uint16(buf[r+offKeyLen:]))
-	return buf[r+offKeyStr : int(r)+offKeyStr+kl]
+	off, kl := r.keyStr(buf), int(binary.LittleEndian.Uint16(buf[r+offKeyLen:]))
+	return buf[off : off+kl]
 }
 
 func (r qref) data(buf []byte) []byte {
 	l, kl := r.size(buf), int(binary.LittleEndian.Uint16(buf[r+offKeyLen:]))
-	return buf[int(r)+offKeyStr+kl : int(r)+l]
+	return buf[r.keyStr(buf)+kl : int(r)+l]
 }
 
 // Reads buffer from qref position returning CacheEntry which is not safe to be used without shard lock.
 func (r qref) read(buf []byte) (*CacheEntry, error) {
-	if !r.valid(buf) {
+	if !r.valid(buf) || !r.validNonce(buf) {
 		return nil, ErrCacheEntryCorrupted
 	}
-	return &CacheEntry{
-		TS:   r.ts(buf),
-		Hash: r.hash(buf),
-		Key:  r.key(buf),
-		Data: r.data(buf), // could save 2 buffer reads here - beauty first
-	}, nil
+	ce := &CacheEntry{
+		TS:     r.ts(buf),
+		Hash:   r.hash(buf),
+		Key:    r.key(buf),
+		Data:   r.data(buf), // could save 2 buffer reads here - beauty first
+		Tag:    r.tag(buf),
+		flags:  r.flags(buf),
+		access: r.access(buf),
+		nonce:  r.nonce(buf),
+	}
+	if ce.flags&flagNil != 0 {
+		ce.Data = nil
+	}
+	return ce, nil
 }
 
 // Writes entry into buffer at qref position. If buffer is too small it will panic.
 // NOTE: for efficiency it is assumed that all checks necessary on the buffer availability happen before write was called.
 func (r qref) write(buf []byte, ce *CacheEntry) {
-	binary.LittleEndian.PutUint32(buf[int(r)+offLen:], uint32(ce.Size()))
+	size := ce.Size()
+	r.writeHeader(buf, ce, size)
+	copy(buf[int(r)+size-len(ce.Data):], ce.Data)
+}
+
+// Writes entry header and key into buffer at qref position, size is full size of serialized entry.
+func (r qref) writeHeader(buf []byte, ce *CacheEntry, size int) {
+	binary.LittleEndian.PutUint32(buf[int(r)+offLen:], uint32(size))
 	binary.LittleEndian.PutUint64(buf[int(r)+offTS:], ce.TS)
 	binary.LittleEndian.PutUint64(buf[int(r)+offHash:], ce.Hash)
 	binary.LittleEndian.PutUint16(buf[int(r)+offKeyLen:], uint16(len(ce.Key)))
-	copy(buf[int(r)+offKeyStr:], ce.Key)
-	copy(buf[int(r)+offKeyStr+len(ce.Key):], ce.Data)
+	buf[int(r)+offFlags] = byte(ce.flags)
+	r.writeOptional(buf, ce)
+	off := int(r) + offKeyStr
+	if ce.flags&flagSealed != 0 {
+		buf[off] = byte(len(ce.nonce))
+		off += sizeNonce + copy(buf[off+sizeNonce:], ce.nonce)
+	}
+	copy(buf[off:], ce.Key)
 }
 
 // Plugs empty space between position held and position passed by creating empty cache entry to cover the whole area.
@@ -118,6 +195,23 @@ func (r qref) plug(s qref, buf []byte) {
 	}
 }
 
+// Changes full size of serialized entry, space for it must be already reserved.
+func (r qref) resize(buf []byte, size int) {
+	binary.LittleEndian.PutUint32(buf[int(r)+offLen:], uint32(size))
+}
+
+// Updates entry timestamp and flags in place, entry is accessed when it is stamped.
+func (r qref) restamp(buf []byte, ts uint64, flags entryFlags) {
+	binary.LittleEndian.PutUint64(buf[int(r)+offTS:], ts)
+	buf[int(r)+offFlags] = byte(flags)
+	r.touch(buf, 0)
+}
+
+// Adds flags to the entry header.
+func (r qref) mark(buf []byte, flags entryFlags) {
+	buf[int(r)+offFlags] |= byte(flags)
+}
+
 // If hash is 0 entry was explicitly deleted.
 func (r qref) clearHash(buf []byte) {
 	binary.LittleEndian.PutUint64(buf[int(r)+offHash:], 0)
@@ -127,10 +221,15 @@ func (r qref) clearHash(buf []byte) {
 // NOTE: In some cases (usually in callbacks) for efficiency Key and Data fields give access to underlying queue buffer, which is only
 // safe while shard lock is held. To make this obvious Copy methods exit - it is up to user to decide how to use it.
 type CacheEntry struct {
-	TS   uint64
-	Hash uint64
-	Key  []byte
-	Data []byte
+	TS    uint64
+	Hash  uint64
+	Key   []byte
+	Data  []byte
+	Tag   uint16 // user defined metadata, 0 unless entry was stored with SetWithTag and header profile keeps tags
+	flags entryFlags
+	// time of the last read as number of seconds since TS, kept so restored entries do not look idle
+	access uint32
+	nonce  []byte // nonce Data was sealed with when flagSealed is set
 }
 
 // Size returns number of bytes needed to store entry. When called on nil entry returns size of the header - minimal size of any entry in the cache.
@@ -139,6 +238,9 @@ func (ce *CacheEntry) Size() int {
 	l := offKeyStr
 	if ce != nil {
 		l += len(ce.Key) + len(ce.Data)
+		if ce.flags&flagSealed != 0 {
+			l += sizeNonce + len(ce.nonce)
+		}
 	}
 	return l
 }
@@ -153,7 +255,7 @@ func (ce *CacheEntry) CopyKeyData() (s []byte) {
 	l := len(ce.Key)
 	if l > 0 {
 		s = make([]byte, l)
-		copy(s, ce.Data)
+		copy(s, ce.Key)
 	}
 	return
 }
@@ -169,3 +271,72 @@ func (ce *CacheEntry) CopyData(newCap int) []byte {
 	copy(s, ce.Data)
 	return s
 }
+
+// this is a safeguard, breaking on compile time in case CacheEntry does not implement standard binary (un)marshaling.
+var (
+	_ encoding.BinaryMarshaler   = (*CacheEntry)(nil)
+	_ encoding.BinaryUnmarshaler = (*CacheEntry)(nil)
+)
+
+// MarshalBinary returns entry in the same form it is stored in cache's queue.
+func (ce *CacheEntry) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, ce.Size())
+	qref(0).write(buf, ce)
+	return buf, nil
+}
+
+// UnmarshalBinary decodes entry produced by MarshalBinary. Key and Data are copied, so data could be reused.
+func (ce *CacheEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < offKeyStr || qref(0).size(data) != len(data) {
+		return ErrCacheEntryCorrupted
+	}
+	e, err := qref(0).read(data)
+	if err != nil {
+		return err
+	}
+	e.Key = e.CopyKeyData()
+	if e.nonce != nil {
+		e.nonce = append([]byte(nil), e.nonce...)
+	}
+	if e.Data != nil {
+		e.Data = e.CopyData(0)
+	}
+	*ce = *e
+	return nil
+}
+
+// WriteTo writes entry to w using the same framing entries have in cache's queue.
+func (ce *CacheEntry) WriteTo(w io.Writer) (int64, error) {
+	buf, _ := ce.MarshalBinary()
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom reads single entry written by WriteTo from r replacing content of ce. Unlike io.ReaderFrom
+// it stops after entry is read, so entries could be read from a stream one by one.
+func (ce *CacheEntry) ReadFrom(r io.Reader) (int64, error) {
+	var size [sizeLen]byte
+	n, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return int64(n), err
+	}
+	l := int(binary.LittleEndian.Uint32(size[:]))
+	if l < offKeyStr {
+		return int64(n), ErrCacheEntryCorrupted
+	}
+	buf := make([]byte, l)
+	copy(buf, size[:])
+	m, err := io.ReadFull(r, buf[sizeLen:])
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return int64(n + m), err
+	}
+	e, err := qref(0).read(buf)
+	if err != nil {
+		return int64(n + m), err
+	}
+	*ce = *e
+	return int64(n + m), nil
+}
@@ -2,6 +2,8 @@ package bigcache
 
 import (
 	"bytes"
+	"encoding/gob"
+	"io"
 	"math/rand"
 	"testing"
 	"time"
@@ -55,3 +57,122 @@ func TestPlug(t *testing.T) {
 	noError(t, err)
 	assertEqual(t, 100, ce.Size())
 }
+
+func TestWriteToReadFrom(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var buf bytes.Buffer
+	first := makeCacheEntry("key", "data")
+	second := makeCacheEntry("", "")
+	second.flags = flagNegative
+
+	// when
+	n1, err1 := first.WriteTo(&buf)
+	n2, err2 := second.WriteTo(&buf)
+
+	// then
+	noError(t, err1)
+	noError(t, err2)
+	assertEqual(t, int64(first.Size()+second.Size()), n1+n2)
+	assertEqual(t, buf.Len(), int(n1+n2))
+
+	// when
+	var ce1, ce2, ce3 CacheEntry
+	r1, err1 := ce1.ReadFrom(&buf)
+	r2, err2 := ce2.ReadFrom(&buf)
+	_, err3 := ce3.ReadFrom(&buf)
+
+	// then
+	noError(t, err1)
+	noError(t, err2)
+	assertEqual(t, n1, r1)
+	assertEqual(t, n2, r2)
+	assertEqual(t, *first, ce1)
+	assertEqual(t, *second, ce2)
+	assertEqual(t, io.EOF, err3)
+}
+
+func TestReadFromTruncated(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var buf bytes.Buffer
+	makeCacheEntry("key", "data").WriteTo(&buf)
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-1])
+
+	// when
+	var ce CacheEntry
+	_, err := ce.ReadFrom(truncated)
+
+	// then
+	assertEqual(t, io.ErrUnexpectedEOF, err)
+
+	// when
+	_, err = ce.ReadFrom(bytes.NewReader([]byte{1, 0, 0, 0}))
+
+	// then
+	assertEqual(t, ErrCacheEntryCorrupted, err)
+}
+
+func TestMarshalUnmarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ce := makeCacheEntry("key", "data")
+
+	// when
+	data, err := ce.MarshalBinary()
+	noError(t, err)
+	var ce1 CacheEntry
+	err = ce1.UnmarshalBinary(data)
+
+	// then
+	noError(t, err)
+	assertEqual(t, *ce, ce1)
+
+	// when - decoded entry does not share memory with the source
+	data[offKeyStr] = 'x'
+
+	// then
+	assertEqual(t, []byte("key"), ce1.Key)
+
+	// when
+	err = ce1.UnmarshalBinary(data[:len(data)-1])
+
+	// then
+	assertEqual(t, ErrCacheEntryCorrupted, err)
+}
+
+func TestBinaryMarshalingIsQueueLayout(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ce := makeCacheEntry("key", "data")
+	q := newBytesQueue(100, 0, newNopLogger())
+	ref, _ := q.push(ce)
+
+	// when
+	data, _ := ce.MarshalBinary()
+
+	// then
+	assertEqual(t, q.array[ref:ref.idx()+ce.Size()], data)
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var buf bytes.Buffer
+	ce := makeCacheEntry("key", "data")
+
+	// when
+	err := gob.NewEncoder(&buf).Encode(ce)
+	noError(t, err)
+	var ce1 CacheEntry
+	err = gob.NewDecoder(&buf).Decode(&ce1)
+
+	// then
+	noError(t, err)
+	assertEqual(t, *ce, ce1)
+}
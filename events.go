@@ -0,0 +1,118 @@
+package bigcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const defaultEventBufferSize = 1024
+
+// EventOp identifies kind of mutation reported by CacheEvent.
+type EventOp int
+
+const (
+	EventSet    EventOp = iota + 1 // entry was stored by Set() or Append()
+	EventDelete                    // entry was removed as a result of Delete() call
+	EventEvict                     // entry was evicted because it expired or there was no space left
+)
+
+// CacheEvent describes single successful cache mutation. Key is a copy and is safe to keep.
+// For already hashed keys Key is empty and only Hash is set.
+type CacheEvent struct {
+	Op     EventOp
+	Key    string
+	Hash   uint64
+	Reason RemoveReason
+	Size   int // for EventEvict number of bytes entry occupied in shard's queue (header included), 0 otherwise
+}
+
+// eventBroker fans out cache events to subscribers. It never blocks the caller: when subscriber's
+// buffer is full event is dropped and counted.
+type eventBroker struct {
+	sync.RWMutex
+	subscribers map[int]chan CacheEvent
+	lastID      int
+	bufferSize  int
+	active      int32
+	dropped     int64
+	closed      bool
+}
+
+func newEventBroker(bufferSize int) *eventBroker {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+	return &eventBroker{
+		subscribers: make(map[int]chan CacheEvent),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (b *eventBroker) subscribe() (<-chan CacheEvent, func()) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed {
+		ch := make(chan CacheEvent)
+		close(ch)
+		return ch, func() {}
+	}
+	b.lastID++
+	id, ch := b.lastID, make(chan CacheEvent, b.bufferSize)
+	b.subscribers[id] = ch
+	atomic.StoreInt32(&b.active, int32(len(b.subscribers)))
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.Lock()
+			defer b.Unlock()
+			if _, ok := b.subscribers[id]; ok {
+				delete(b.subscribers, id)
+				close(ch)
+			}
+			atomic.StoreInt32(&b.active, int32(len(b.subscribers)))
+		})
+	}
+}
+
+// enabled is cheap check to avoid copying keys when nobody listens.
+func (b *eventBroker) enabled() bool {
+	return atomic.LoadInt32(&b.active) > 0
+}
+
+func (b *eventBroker) publish(op EventOp, key []byte, hash uint64, reason RemoveReason, size int) {
+	if !b.enabled() {
+		return
+	}
+	ev := CacheEvent{Op: op, Key: string(key), Hash: hash, Reason: reason, Size: size}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			atomic.AddInt64(&b.dropped, 1)
+		}
+	}
+}
+
+// close stops publishing and closes subscribers channels, events already buffered in them are still delivered.
+// It waits for publishing in progress to complete.
+func (b *eventBroker) close() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.closed = true
+	for id, ch := range b.subscribers {
+		delete(b.subscribers, id)
+		close(ch)
+	}
+	atomic.StoreInt32(&b.active, 0)
+}
+
+func (b *eventBroker) droppedEvents() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
@@ -0,0 +1,111 @@
+package bigcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeReceivesMutations(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}, &clock)
+	events, cancel := cache.Subscribe()
+	defer cancel()
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Set("key2", []byte("value2"))
+	cache.Delete("key2")
+	clock.set(5)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	expected := []CacheEvent{
+		{Op: EventSet, Key: "key", Hash: cache.hash.Sum64("key"), Reason: NoReason},
+		{Op: EventSet, Key: "key2", Hash: cache.hash.Sum64("key2"), Reason: NoReason},
+		{Op: EventDelete, Key: "key2", Hash: cache.hash.Sum64("key2"), Reason: Deleted},
+		{Op: EventEvict, Key: "key", Hash: cache.hash.Sum64("key"), Reason: Expired, Size: (&CacheEntry{Key: []byte("key"), Data: []byte("value")}).Size()},
+	}
+	for _, e := range expected {
+		assertEqual(t, e, <-events)
+	}
+}
+
+func TestSubscribeMultipleAndCancel(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	first, cancelFirst := cache.Subscribe()
+	second, cancelSecond := cache.Subscribe()
+	defer cancelSecond()
+
+	// when
+	cache.Set("key", []byte("value"))
+	cancelFirst()
+	cancelFirst()
+	cache.Set("key", []byte("value"))
+
+	// then
+	assertEqual(t, EventSet, (<-first).Op)
+	_, ok := <-first
+	assertEqual(t, false, ok)
+	assertEqual(t, EventSet, (<-second).Op)
+	assertEqual(t, EventSet, (<-second).Op)
+}
+
+func TestSlowSubscriberDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := DefaultConfig(5 * time.Second)
+	config.EventBufferSize = 2
+	cache, _ := NewBigCache(config)
+	_, cancel := cache.Subscribe()
+	defer cancel()
+
+	// when
+	for i := 0; i < 10; i++ {
+		cache.Set("key", []byte("value"))
+	}
+
+	// then
+	assertEqual(t, int64(8), cache.DroppedEvents())
+}
+
+func TestCloseClosesSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	events, cancel := cache.Subscribe()
+	cache.Set("key", []byte("value"))
+	cache.Delete("key")
+
+	// when
+	noError(t, cache.Close())
+	cancel()
+	cache.Set("key", []byte("value"))
+
+	// then
+	var ops []EventOp
+	for e := range events {
+		ops = append(ops, e.Op)
+	}
+	assertEqual(t, []EventOp{EventSet, EventDelete}, ops)
+
+	// when
+	late, cancelLate := cache.Subscribe()
+	defer cancelLate()
+
+	// then
+	_, ok := <-late
+	assertEqual(t, false, ok)
+}
@@ -0,0 +1,113 @@
+package bigcache
+
+import "sync"
+
+// EvictionPolicy selects entries evicted when shard runs out of space.
+type EvictionPolicy int
+
+const (
+	// FIFO evicts the oldest entries first. It is the cheapest policy as it needs no bookkeeping on reads.
+	FIFO EvictionPolicy = iota
+	// SLRU is a segmented LRU: new entries are probationary and are promoted to protected segment on their second
+	// read by Get. Protected entries reaching the head of the queue are moved back to its tail as probationary
+	// instead of being evicted, so entries read repeatedly survive bursts of entries written or read once, i.e. scans.
+	// Moved entries keep their timestamps, so demotion does not extend their life: entry which outlived LifeWindow
+	// is evicted instead of being moved, and expired entry moved behind newer ones is removed when it reaches the head
+	// of the queue again. It costs an additional lock and map update on every hit and copying of promoted entries on
+	// eviction.
+	SLRU
+)
+
+// protectedSet keeps references to entries read at least once, the ones read again are in protected segment of
+// SLRU. Unlike shard index it is updated by readers holding shard read lock, so it has its own lock.
+type protectedSet struct {
+	sync.Mutex
+	refs map[qref]bool // true for protected entries
+}
+
+func newProtectedSet(policy EvictionPolicy) *protectedSet {
+	if policy != SLRU {
+		return nil
+	}
+	return &protectedSet{refs: make(map[qref]bool)}
+}
+
+// promote records read of the entry, moving it to protected segment when it was read before.
+func (p *protectedSet) promote(ref qref) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	_, seen := p.refs[ref]
+	p.refs[ref] = seen
+}
+
+// demote moves entry to probationary segment reporting if it was protected.
+func (p *protectedSet) demote(ref qref) bool {
+	if p == nil {
+		return false
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	protected := p.refs[ref]
+	delete(p.refs, ref)
+	return protected
+}
+
+func (p *protectedSet) reset() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	p.refs = make(map[qref]bool)
+}
+
+// demoteProtectedWithoutLock moves protected entries from the head of the queue to its tail, so the oldest
+// probationary entry becomes eviction victim. Moved entries keep their timestamps, protected entry which is already
+// expired becomes the victim itself. When there is no room to move protected entry the oldest entries following it
+// are evicted, true is reported then.
+func (s *cacheShard) demoteProtectedWithoutLock() bool {
+	for {
+		oldest, err := s.entries.oldest()
+		if err != nil {
+			return false
+		}
+		hash := s.entries.getHash(oldest)
+		if hash == 0 || !s.protected.demote(oldest) || olderThan(s.clock.epoch(), s.entries.getTS(oldest), s.lifeWindow) {
+			return false
+		}
+		ce, _ := s.entries.get(oldest)
+		_ = duplicate(ce)
+		_, _ = s.entries.pop()
+		s.unindex(hash, oldest)
+
+		evicted := false
+		ref, err := s.entries.alloc(ce.Size())
+		for err != nil {
+			// queue is left intact when allocation fails, so popped entries could still be read
+			victim, perr := s.entries.pop()
+			if perr != nil {
+				s.removedWithoutLock(oldest, hash, NoSpace)
+				return true
+			}
+			if h := s.entries.getHash(victim); h != 0 {
+				s.unindex(h, victim)
+				s.removedWithoutLock(victim, h, NoSpace)
+				evicted = true
+			} else {
+				s.reclaimed(victim)
+			}
+			ref, err = s.entries.alloc(ce.Size())
+		}
+		ref.write(s.entries.array, ce)
+		s.index(hash, ref)
+		if evicted {
+			return true
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSLRUKeepsReadEntriesOnScan(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		policy    EvictionPolicy
+		survivors bool
+	}{
+		{FIFO, false},
+		{SLRU, true},
+	} {
+		t.Run(fmt.Sprintf("policy-%d", tc.policy), func(t *testing.T) {
+			// given
+			cache, _ := NewBigCache(Config{
+				Shards:             1,
+				LifeWindow:         time.Minute,
+				MaxEntriesInWindow: 10,
+				MaxEntrySize:       256,
+				HardMaxCacheSize:   1,
+				EvictionPolicy:     tc.policy,
+			})
+			value := blob('a', 1024)
+			cache.Set("hot", value)
+			cache.Get("hot")
+
+			// when - scan writes entries which are never read, overflowing cache several times
+			for i := 0; i < 4096; i++ {
+				cache.Set(fmt.Sprintf("scan-%d", i), value)
+				if i%256 == 0 {
+					cache.Get("hot")
+				}
+			}
+			_, err := cache.Get("hot")
+
+			// then
+			assertEqual(t, tc.survivors, err == nil)
+			assertEqual(t, true, cache.Stats().EvictedNoSpace > 0)
+		})
+	}
+}
+
+func TestSLRUDemotedEntryIsEvictedWhenNotReadAgain(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var evicted []string
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		EvictionPolicy:     SLRU,
+		OnRemove: func(ce *CacheEntry, reason RemoveReason) {
+			evicted = append(evicted, ce.CopyKey())
+		},
+	})
+	cache.Set("hot", []byte("value"))
+	cache.Get("hot")
+	cache.Get("hot")
+
+	// when
+	for i := 0; i < 4096; i++ {
+		cache.Set(fmt.Sprintf("scan-%d", i), blob('a', 1024))
+	}
+	_, err := cache.Get("hot")
+
+	// then
+	assertEqual(t, true, err != nil)
+	assertEqual(t, true, len(evicted) > 0)
+	assertEqual(t, true, cache.Verify() == nil)
+}
+
+func TestSLRUPromotesOnSecondRead(t *testing.T) {
+	t.Parallel()
+
+	for _, reads := range []int{1, 2} {
+		t.Run(fmt.Sprintf("reads-%d", reads), func(t *testing.T) {
+			// given
+			cache, _ := NewBigCache(Config{
+				Shards:             1,
+				LifeWindow:         time.Minute,
+				MaxEntriesInWindow: 10,
+				MaxEntrySize:       256,
+				HardMaxCacheSize:   1,
+				EvictionPolicy:     SLRU,
+			})
+			cache.Set("hot", []byte("value"))
+			for i := 0; i < reads; i++ {
+				cache.Get("hot")
+			}
+
+			// when - scan overflows cache once
+			for i := 0; i < 1200; i++ {
+				cache.Set(fmt.Sprintf("scan-%d", i), blob('a', 1024))
+			}
+
+			// then
+			assertEqual(t, reads == 2, cache.Has("hot"))
+		})
+	}
+}
+
+func TestSLRUDemotedEntryKeepsTimestamp(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         100 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		EvictionPolicy:     SLRU,
+	}, clock)
+	cache.Set("hot", []byte("value"))
+	cache.Get("hot")
+	cache.Get("hot")
+
+	// when
+	clock.set(50)
+	for i := 0; i < 1200; i++ {
+		cache.Set(fmt.Sprintf("scan-%d", i), blob('a', 1024))
+	}
+
+	// then - hot entry was demoted once
+	assertEqual(t, true, cache.Stats().EvictedNoSpace > 0)
+	_, info, err := cache.GetWithInfo("hot")
+	noError(t, err)
+	assertEqual(t, uint64(0), info.Timestamp)
+	assertEqual(t, 50*time.Second, info.Age)
+	assertEqual(t, 50*time.Second, info.TTL)
+	assertEqual(t, 1, cache.ExpiringWithin(60*time.Second))
+}
+
+func TestSLRUDemotionDoesNotExtendLife(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	var reasons []RemoveReason
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         100 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		EvictionPolicy:     SLRU,
+		OnRemove: func(ce *CacheEntry, reason RemoveReason) {
+			if string(ce.Key) == "hot" {
+				reasons = append(reasons, reason)
+			}
+		},
+	}, clock)
+	cache.Set("hot", []byte("value"))
+
+	// when - hot entry is read all the time while scans keep overflowing cache
+	for i := 0; i < 6000; i++ {
+		clock.set(uint64(i / 20))
+		cache.Set(fmt.Sprintf("scan-%d", i), blob('a', 1024))
+		if i%100 == 0 {
+			cache.Get("hot")
+		}
+	}
+
+	// then
+	assertEqual(t, 1, len(reasons))
+	assertEqual(t, false, cache.Has("hot"))
+}
@@ -0,0 +1,34 @@
+package bigcache
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+)
+
+var (
+	ErrVarAlreadyExposed = errors.New("expvar with this name is already published")
+
+	expvarLock sync.Mutex
+)
+
+// ExposeVars publishes cache's Stats, Len and Capacity under expvar with provided name, so they are
+// available at /debug/vars. Values are computed lazily when variable is read.
+// Since expvar does not allow to publish the same name twice ErrVarAlreadyExposed is returned instead of panic.
+func (c *BigCache) ExposeVars(name string) error {
+
+	expvarLock.Lock()
+	defer expvarLock.Unlock()
+
+	if expvar.Get(name) != nil {
+		return ErrVarAlreadyExposed
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"stats":    c.Stats(),
+			"len":      c.Len(),
+			"capacity": c.Capacity(),
+		}
+	}))
+	return nil
+}
@@ -0,0 +1,35 @@
+package bigcache
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestExposeVars(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("value"))
+	cache.Get("key")
+
+	// when
+	err := cache.ExposeVars("bigcache_test_expose")
+	errAgain := cache.ExposeVars("bigcache_test_expose")
+
+	// then
+	noError(t, err)
+	assertEqual(t, ErrVarAlreadyExposed, errAgain)
+
+	var v struct {
+		Stats    Stats `json:"stats"`
+		Len      int   `json:"len"`
+		Capacity int   `json:"capacity"`
+	}
+	noError(t, json.Unmarshal([]byte(expvar.Get("bigcache_test_expose").String()), &v))
+	assertEqual(t, int64(1), v.Stats.Hits)
+	assertEqual(t, 1, v.Len)
+	assertEqual(t, cache.Capacity(), v.Capacity)
+}
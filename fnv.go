@@ -7,7 +7,23 @@ func newDefaultHasher() Hasher {
 	return fnv64a{}
 }
 
-type fnv64a struct{}
+// newSeededHasher returns FNV-1a Hasher which offset basis is derived from seed, so different seeds map keys
+// differently. Zero seed gives the same hashes as default hasher.
+func newSeededHasher(seed uint64) Hasher {
+	if seed == 0 {
+		return fnv64a{}
+	}
+	var basis uint64 = offset64
+	for i := 0; i < 8; i++ {
+		basis ^= seed >> (8 * i) & 0xff
+		basis *= prime64
+	}
+	return fnv64a{basis: basis}
+}
+
+type fnv64a struct {
+	basis uint64 // offset basis, 0 means standard one
+}
 
 const (
 	// offset64 FNVa offset basis. See https://en.wikipedia.org/wiki/Fowler–Noll–Vo_hash_function#FNV-1a_hash
@@ -18,7 +34,18 @@ const (
 
 // Sum64 gets the string and returns its uint64 hash value.
 func (f fnv64a) Sum64(key string) uint64 {
-	var hash uint64 = offset64
+	hash := f.offset()
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+
+	return hash
+}
+
+// Sum64Bytes gets the byte slice and returns its uint64 hash value, which is the same as Sum64 returns for the same key.
+func (f fnv64a) Sum64Bytes(key []byte) uint64 {
+	hash := f.offset()
 	for i := 0; i < len(key); i++ {
 		hash ^= uint64(key[i])
 		hash *= prime64
@@ -26,3 +53,10 @@ func (f fnv64a) Sum64(key string) uint64 {
 
 	return hash
 }
+
+func (f fnv64a) offset() uint64 {
+	if f.basis == 0 {
+		return offset64
+	}
+	return f.basis
+}
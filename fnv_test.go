@@ -3,6 +3,7 @@ package bigcache
 import (
 	"hash/fnv"
 	"testing"
+	"time"
 )
 
 type testCase struct {
@@ -28,6 +29,54 @@ func TestFnvHashSum64(t *testing.T) {
 	}
 }
 
+func TestSeededHasher(t *testing.T) {
+	t.Parallel()
+
+	// given
+	key := "some longer and more complicated text"
+	seeded := newSeededHasher(42)
+
+	// then
+	assertEqual(t, stdLibFnvSum64(key), newSeededHasher(0).Sum64(key))
+	assertEqual(t, seeded.Sum64(key), newSeededHasher(42).Sum64(key))
+	assertEqual(t, seeded.Sum64(key), seeded.(BytesHasher).Sum64Bytes([]byte(key)))
+	assertEqual(t, false, seeded.Sum64(key) == stdLibFnvSum64(key))
+	assertEqual(t, false, seeded.Sum64(key) == newSeededHasher(43).Sum64(key))
+}
+
+func TestCacheWithHashSeed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HashSeed:           42,
+	}
+	cache, err := NewBigCache(config)
+	noError(t, err)
+
+	// when
+	cache.Set("key", []byte("value"))
+	value, err := cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	value, err = cache.GetHashed(newSeededHasher(42).Sum64("key"))
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+
+	// when
+	config.Hasher = NewConstantHasher(1)
+	_, err = NewBigCache(config)
+
+	// then
+	assertEqual(t, ErrInvalidHashSeed, err)
+}
+
 func stdLibFnvSum64(key string) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(key))
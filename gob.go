@@ -0,0 +1,73 @@
+package bigcache
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// GobEntry is the form EncodeGob writes entries in. It is a plain struct, so streams could be decoded by programs
+// which know nothing about cache's binary framing.
+type GobEntry struct {
+	Key  string
+	Data []byte
+	TS   uint64
+	Tag  uint16
+	Nil  bool   // gob does not tell nil slices from empty ones, Data of entries stored as nil is nil
+	Hash uint64 // set only for entries stored by SetHashed without key
+}
+
+// EncodeGob writes all live entries to w as a gob encoded slice of GobEntry values. Entries are decrypted when
+// Config.Cipher is set. It is slower and needs more memory than Save, as the whole slice is built before it is
+// encoded.
+// NOTE: methods are not named GobEncode and GobDecode to avoid confusion with gob.GobEncoder interface.
+func (c *BigCache) EncodeGob(w io.Writer) error {
+	entries := make([]GobEntry, 0, c.Len())
+	err := c.Range(func(ce *CacheEntry) error {
+		e := GobEntry{Key: string(ce.Key), Data: ce.Data, TS: ce.TS, Tag: ce.Tag, Nil: ce.Data == nil}
+		if len(ce.Key) == 0 {
+			e.Hash = ce.Hash
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// DecodeGob stores entries written by EncodeGob replacing entries with the same keys. Entries keep their
+// timestamps. Keys are hashed with cache's Hasher, so it may differ from the one of the cache which encoded them,
+// except for entries without keys which keep their hashes.
+func (c *BigCache) DecodeGob(r io.Reader) error {
+	var entries []GobEntry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	for _, e := range entries {
+		ce := CacheEntry{TS: e.TS, Hash: e.Hash, Key: []byte(e.Key), Data: e.Data, Tag: e.Tag}
+		if e.Key != "" {
+			ce.Hash = c.hash.Sum64(e.Key)
+		}
+		if e.Nil {
+			ce.Data, ce.flags = nil, flagNil
+		} else if ce.Data == nil {
+			ce.Data = []byte{}
+		}
+		shard := c.getShard(ce.Hash)
+		if err := shard.seal(&ce); err != nil {
+			return err
+		}
+		if err := shard.restore(&ce); err != nil {
+			return err
+		}
+	}
+	return nil
+}
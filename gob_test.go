@@ -0,0 +1,147 @@
+package bigcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeGob(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}
+	cache, _ := NewBigCache(config)
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	cache.Set("nil", nil)
+	cache.Set("empty", []byte{})
+	var buf bytes.Buffer
+
+	// when
+	err := cache.EncodeGob(&buf)
+	decoded, _ := NewBigCache(config)
+	decodeErr := decoded.DecodeGob(&buf)
+
+	// then
+	noError(t, err)
+	noError(t, decodeErr)
+	assertEqual(t, cache.Len(), decoded.Len())
+	for i := 0; i < 100; i++ {
+		value, err := decoded.Get(fmt.Sprintf("key-%d", i))
+		noError(t, err)
+		assertEqual(t, []byte(fmt.Sprintf("value-%d", i)), value)
+	}
+	value, err := decoded.Get("nil")
+	noError(t, err)
+	assertEqual(t, true, value == nil)
+	value, err = decoded.Get("empty")
+	noError(t, err)
+	assertEqual(t, true, value != nil && len(value) == 0)
+}
+
+func TestEncodeGobWritesPlainEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	cache.Set("nil", nil)
+	cache.Set("empty", []byte{})
+	cache.SetHashed(42, []byte("hashed"))
+	var buf bytes.Buffer
+
+	// when
+	noError(t, cache.EncodeGob(&buf))
+	var entries []GobEntry
+	noError(t, gob.NewDecoder(&buf).Decode(&entries))
+
+	// then
+	assertEqual(t, 3, len(entries))
+	byKey := make(map[string]GobEntry, len(entries))
+	for _, e := range entries {
+		byKey[e.Key] = e
+	}
+	assertEqual(t, true, byKey["nil"].Nil)
+	assertEqual(t, uint64(0), byKey["nil"].Hash)
+	assertEqual(t, false, byKey["empty"].Nil)
+	assertEqual(t, uint64(42), byKey[""].Hash)
+	assertEqual(t, []byte("hashed"), byKey[""].Data)
+}
+
+// shiftedHasher hashes keys differently than default hasher.
+type shiftedHasher struct{}
+
+func (shiftedHasher) Sum64(key string) uint64 {
+	return fnv64a{}.Sum64(key) + 1
+}
+
+func TestDecodeGobRehashesKeys(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}
+	cache, _ := NewBigCache(config)
+	cache.Set("key", []byte("value"))
+	cache.SetHashed(42, []byte("hashed"))
+	var buf bytes.Buffer
+	noError(t, cache.EncodeGob(&buf))
+	config.Hasher = shiftedHasher{}
+	decoded, _ := NewBigCache(config)
+
+	// when
+	err := decoded.DecodeGob(&buf)
+
+	// then
+	noError(t, err)
+	value, err := decoded.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	value, err = decoded.GetHashed(42)
+	noError(t, err)
+	assertEqual(t, []byte("hashed"), value)
+}
+
+func TestGobKeepsTags(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	config := Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}
+	cache, _ := NewBigCache(config)
+	cache.SetWithTag("key", []byte("value"), 7)
+	var buf bytes.Buffer
+	noError(t, cache.EncodeGob(&buf))
+	decoded, _ := NewBigCache(config)
+
+	// when
+	err := decoded.DecodeGob(&buf)
+
+	// then
+	noError(t, err)
+	_, info, err := decoded.GetWithInfo("key")
+	noError(t, err)
+	assertEqual(t, uint16(7), info.Tag)
+}
@@ -6,3 +6,26 @@ package bigcache
 type Hasher interface {
 	Sum64(string) uint64
 }
+
+// BytesHasher is optionally implemented by Hasher to hash keys kept in byte slices, so methods like SetBytes do not
+// have to convert them to strings. It must return the same hash Sum64 returns for the same key.
+type BytesHasher interface {
+	Sum64Bytes([]byte) uint64
+}
+
+// NewConstantHasher returns Hasher mapping every key to the same value, so all keys collide. It is meant for tests
+// exercising collision handling and must never be used in production as every lookup becomes a key comparison
+// in a single chain.
+func NewConstantHasher(value uint64) Hasher {
+	return constantHasher(value)
+}
+
+type constantHasher uint64
+
+func (h constantHasher) Sum64(string) uint64 {
+	return uint64(h)
+}
+
+func (h constantHasher) Sum64Bytes([]byte) uint64 {
+	return uint64(h)
+}
@@ -1,7 +1,38 @@
 package bigcache
 
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
 type hashStub uint64
 
 func (stub hashStub) Sum64(_ string) uint64 {
 	return uint64(stub)
 }
+
+func TestConstantHasherCollisions(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Hasher:             NewConstantHasher(42),
+	})
+
+	// when
+	for i := 0; i < 3; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	value, err := cache.GetBytes([]byte("key1"))
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value1"), value)
+	assertEqual(t, 3, cache.Len())
+	assertEqual(t, int64(2), cache.Stats().Collisions)
+}
@@ -0,0 +1,27 @@
+//go:build !bigcache_full_header
+// +build !bigcache_full_header
+
+package bigcache
+
+// Compact header profile is the default one, it keeps only fields every entry needs. Entries have no user tag and
+// time of the last access, so SetWithTag with non-zero tag returns ErrHeaderField and Config.IdleTimeout is
+// rejected, build with bigcache_full_header tag to get them. Snapshots and write-ahead logs are only readable by
+// builds using the same profile.
+const (
+	sizeTag    = 0
+	sizeAccess = 0
+
+	headerProfile = "compact"
+)
+
+func (r qref) tag([]byte) uint16 {
+	return 0
+}
+
+func (r qref) access([]byte) uint32 {
+	return 0
+}
+
+func (r qref) touch([]byte, uint32) {}
+
+func (r qref) writeOptional([]byte, *CacheEntry) {}
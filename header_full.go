@@ -0,0 +1,34 @@
+//go:build bigcache_full_header
+// +build bigcache_full_header
+
+package bigcache
+
+import "encoding/binary"
+
+// Full header profile is selected by bigcache_full_header build tag: entries keep user tag and time of the last
+// access, so SetWithTag and Config.IdleTimeout are supported, at the cost of 6 bytes per entry.
+const (
+	sizeTag    = 2 // Number of bytes used for user tag
+	sizeAccess = 4 // Number of bytes used for time of the last access, in seconds since timestamp
+
+	headerProfile = "full"
+)
+
+func (r qref) tag(buf []byte) uint16 {
+	return binary.LittleEndian.Uint16(buf[r+offTag:])
+}
+
+func (r qref) access(buf []byte) uint32 {
+	return binary.LittleEndian.Uint32(buf[r+offAccess:])
+}
+
+// Records time of the last access as number of seconds since entry timestamp.
+func (r qref) touch(buf []byte, access uint32) {
+	binary.LittleEndian.PutUint32(buf[int(r)+offAccess:], access)
+}
+
+// Writes optional header fields.
+func (r qref) writeOptional(buf []byte, ce *CacheEntry) {
+	binary.LittleEndian.PutUint16(buf[int(r)+offTag:], ce.Tag)
+	binary.LittleEndian.PutUint32(buf[int(r)+offAccess:], ce.access)
+}
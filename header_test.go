@@ -0,0 +1,74 @@
+package bigcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeaderProfileRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	// given
+	required := sizeLen + sizeTS + sizeHash + sizeKeyLen + sizeFlags
+	optional := map[string]int{"full": 6, "compact": 0}[headerProfile]
+	ce := &CacheEntry{TS: 100, Hash: 42, Key: []byte("key"), Data: []byte("value"), Tag: 7, access: 3}
+
+	// when
+	buf, _ := ce.MarshalBinary()
+	var restored CacheEntry
+	err := restored.UnmarshalBinary(buf)
+
+	// then
+	noError(t, err)
+	assertEqual(t, required+optional+len("key")+len("value"), len(buf))
+	assertEqual(t, ce.Size(), len(buf))
+	assertEqual(t, ce.Key, restored.Key)
+	assertEqual(t, ce.Data, restored.Data)
+	assertEqual(t, ce.TS, restored.TS)
+	assertEqual(t, ce.Hash, restored.Hash)
+	if headerHasTag {
+		assertEqual(t, uint16(7), restored.Tag)
+	} else {
+		assertEqual(t, uint16(0), restored.Tag)
+	}
+	if headerHasAccess {
+		assertEqual(t, uint32(3), restored.access)
+	} else {
+		assertEqual(t, uint32(0), restored.access)
+	}
+}
+
+func TestHeaderProfileRejectsMissingFields(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		CleanWindow:        time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		IdleTimeout:        time.Second,
+	}
+
+	// when
+	cache, err := NewBigCache(config)
+
+	// then
+	assertEqual(t, !headerHasAccess, errors.Is(err, ErrHeaderField))
+	if cache != nil {
+		cache.Close()
+	}
+
+	// when
+	config.IdleTimeout = 0
+	cache, _ = NewBigCache(config)
+	defer cache.Close()
+	tagged := cache.SetWithTag("tagged", []byte("value"), 7)
+	untagged := cache.SetWithTag("untagged", []byte("value"), 0)
+
+	// then
+	assertEqual(t, !headerHasTag, errors.Is(tagged, ErrHeaderField))
+	noError(t, untagged)
+}
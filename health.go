@@ -0,0 +1,44 @@
+package bigcache
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Healthy reports if cache is usable, i.e. for readiness probes. It returns ErrCacheClosed after Close,
+// ErrCleanupStopped when background cleanup goroutine has stopped (i.e. because OnRemove panicked) and
+// ErrShardCorrupted when shard bookkeeping is inconsistent. Unlike Verify it does not walk through entries,
+// only a few checks per shard are made, so it is cheap enough to be called often.
+func (c *BigCache) Healthy() error {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return ErrCacheClosed
+	}
+	if c.config.CleanWindow > 0 && atomic.LoadInt32(&c.cleaning) == 0 {
+		return ErrCleanupStopped
+	}
+	for i, shard := range c.getShards() {
+		if err := shard.healthy(); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// healthy checks shard invariants which do not need walking through entries.
+func (s *cacheShard) healthy() error {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	q := s.entries
+	switch {
+	case q.count < 0:
+		return fmt.Errorf("%w: negative number of entries %d", ErrShardCorrupted, q.count)
+	case s.lenWithoutLock() > q.count:
+		return fmt.Errorf("%w: %d entries indexed, %d in queue", ErrShardCorrupted, s.lenWithoutLock(), q.count)
+	case q.head.idx() < 0 || q.tail.idx() < 0 || q.right.idx() > q.cap() || q.head.idx() > q.right.idx() || q.tail.idx() > q.right.idx():
+		return fmt.Errorf("%w: queue pointers head %d, tail %d, right %d out of capacity %d", ErrShardCorrupted,
+			q.head, q.tail, q.right, q.cap())
+	}
+	return nil
+}
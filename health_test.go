@@ -0,0 +1,87 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHealthy(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		CleanWindow:        time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), []byte("value"))
+	}
+
+	// when
+	err := cache.Healthy()
+	closeErr := cache.Close()
+	closedErr := cache.Healthy()
+	doubleCloseErr := cache.Close()
+
+	// then
+	noError(t, err)
+	noError(t, closeErr)
+	assertEqual(t, ErrCacheClosed, closedErr)
+	assertEqual(t, ErrCacheClosed, doubleCloseErr)
+}
+
+func TestHealthyReportsStoppedCleanup(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Second,
+		CleanWindow:        time.Millisecond,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		OnRemove: func(*CacheEntry, RemoveReason) {
+			panic("callback failed")
+		},
+	}, clock)
+	defer cache.Close()
+	cache.Set("key", []byte("value"))
+
+	// when
+	cache.PauseCleanup()
+	clock.set(10)
+	cache.ResumeCleanup()
+	err := cache.Healthy()
+	for i := 0; i < 1000 && err == nil; i++ {
+		time.Sleep(time.Millisecond)
+		err = cache.Healthy()
+	}
+
+	// then
+	assertEqual(t, ErrCleanupStopped, err)
+}
+
+func TestHealthyReportsCorruptedShard(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             2,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+
+	// when
+	cache.shards[1].entries.count = -1
+	err := cache.Healthy()
+
+	// then
+	assertEqual(t, true, errors.Is(err, ErrShardCorrupted))
+}
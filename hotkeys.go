@@ -0,0 +1,122 @@
+package bigcache
+
+import (
+	"sort"
+	"sync"
+)
+
+// hotKeys counts hits of the most read hashes of a shard using space-saving algorithm: when there is no room for
+// a new hash, the least read one is replaced and new hash inherits its count, so counts are upper bounds.
+// Like protectedSet it is updated by readers holding shard read lock, so it has its own lock.
+type hotKeys struct {
+	sync.Mutex
+	counts   map[uint64]uint64
+	capacity int
+}
+
+// hotKey is a hash with its approximate number of hits.
+type hotKey struct {
+	hash  uint64
+	count uint64
+}
+
+func newHotKeys(capacity int) *hotKeys {
+	if capacity <= 0 {
+		return nil
+	}
+	return &hotKeys{counts: make(map[uint64]uint64, capacity), capacity: capacity}
+}
+
+// record counts hit of hash.
+func (h *hotKeys) record(hash uint64) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	if _, found := h.counts[hash]; found || len(h.counts) < h.capacity {
+		h.counts[hash]++
+		return
+	}
+	var victim, least uint64
+	first := true
+	for k, c := range h.counts {
+		if first || c < least {
+			victim, least, first = k, c, false
+		}
+	}
+	delete(h.counts, victim)
+	h.counts[hash] = least + 1
+}
+
+// decay halves all counts forgetting hashes which are not read anymore, so counts cover recent hits.
+func (h *hotKeys) decay() {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	for k, c := range h.counts {
+		if c /= 2; c == 0 {
+			delete(h.counts, k)
+		} else {
+			h.counts[k] = c
+		}
+	}
+}
+
+// appendTo adds tracked hashes to keys.
+func (h *hotKeys) appendTo(keys []hotKey) []hotKey {
+	if h == nil {
+		return keys
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	for k, c := range h.counts {
+		keys = append(keys, hotKey{hash: k, count: c})
+	}
+	return keys
+}
+
+func (h *hotKeys) reset() {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	h.counts = make(map[uint64]uint64, h.capacity)
+}
+
+// HotKeys returns up to n hashes of the most read entries, the most read first. Hits are only tracked when
+// Config.HotKeysPerShard is set, otherwise nil is returned. Counts are approximate and halved at every cleanup,
+// so hashes read recently are preferred. Hashes of removed entries are reported until they are forgotten.
+func (c *BigCache) HotKeys(n int) []uint64 {
+	c.layout.RLock()
+	var keys []hotKey
+	for _, shard := range c.shards {
+		keys = shard.hot.appendTo(keys)
+	}
+	c.layout.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].count != keys[j].count {
+			return keys[i].count > keys[j].count
+		}
+		return keys[i].hash < keys[j].hash
+	})
+	if n > len(keys) {
+		n = len(keys)
+	}
+	if n <= 0 {
+		return nil
+	}
+	hashes := make([]uint64, n)
+	for i := range hashes {
+		hashes[i] = keys[i].hash
+	}
+	return hashes
+}
@@ -0,0 +1,105 @@
+package bigcache
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		HotKeysPerShard:    4,
+	})
+	for hash := uint64(1); hash <= 16; hash++ {
+		cache.SetHashed(hash, []byte("value"))
+	}
+
+	// when
+	for i := 0; i < 10; i++ {
+		cache.GetHashed(7)
+		if i < 5 {
+			cache.GetHashed(3)
+		}
+	}
+	for hash := uint64(1); hash <= 16; hash++ {
+		cache.GetHashed(hash)
+	}
+
+	// then
+	assertEqual(t, []uint64{7, 3}, cache.HotKeys(2))
+	assertEqual(t, 16, len(cache.HotKeys(100)))
+	assertEqual(t, 0, len(cache.HotKeys(0)))
+}
+
+func TestHotKeysAreNotTrackedByDefault(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Minute))
+	cache.Set("key", []byte("value"))
+
+	// when
+	cache.Get("key")
+
+	// then
+	assertEqual(t, 0, len(cache.HotKeys(10)))
+}
+
+func TestHotKeysReplaceLeastRead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	hot := newHotKeys(2)
+	hot.record(1)
+	hot.record(1)
+	hot.record(1)
+	hot.record(2)
+
+	// when
+	hot.record(3)
+	hot.record(3)
+
+	// then
+	assertEqual(t, []hotKey{{hash: 1, count: 3}, {hash: 3, count: 3}}, sortedHotKeys(hot))
+
+	// when
+	hot.decay()
+	hot.decay()
+
+	// then
+	assertEqual(t, 0, len(hot.appendTo(nil)))
+}
+
+func TestHotKeysAreForgottenOnReset(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HotKeysPerShard:    8,
+	})
+	cache.Set("key", []byte("value"))
+	cache.Get("key")
+
+	// when
+	cache.Reset()
+
+	// then
+	assertEqual(t, 0, len(cache.HotKeys(10)))
+}
+
+func sortedHotKeys(h *hotKeys) []hotKey {
+	keys := h.appendTo(nil)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].hash < keys[j].hash })
+	return keys
+}
@@ -0,0 +1,218 @@
+// Package httpcache provides HTTP middleware caching GET responses in BigCache.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rupor-github/bigcache/v3"
+)
+
+var errCorruptedResponse = errors.New("cached response is corrupted")
+
+// Options for HTTP response caching.
+type Options struct {
+	// TTL is time during which cached response is served. When 0 cached response is valid until cache evicts it.
+	TTL time.Duration
+	// Key computes cache key for request, by default host and request URI are used, so virtual hosts sharing
+	// paths do not share responses.
+	Key func(r *http.Request) string
+}
+
+// NewHTTPCache returns middleware which serves GET requests from cache when possible and stores
+// successful downstream responses otherwise. Cache is shared by all clients, so responses are not stored when
+// Cache-Control has no-store, no-cache, private or max-age=0 directive, or when they have Vary header, as cache key
+// does not include request headers. Requests with Authorization header bypass cache. Set-Cookie headers are never
+// stored.
+func NewHTTPCache(cache *bigcache.BigCache, opts Options) func(http.Handler) http.Handler {
+	if opts.Key == nil {
+		opts.Key = func(r *http.Request) string { return r.Host + r.URL.RequestURI() }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := opts.Key(r)
+			if data, err := cache.Get(key); err == nil {
+				if resp, err := decode(data); err == nil && (resp.expires == 0 || time.Now().UnixNano() < resp.expires) {
+					resp.writeTo(w)
+					return
+				}
+				_ = cache.Delete(key)
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status != http.StatusOK || !shared(rec.Header()) {
+				return
+			}
+			header := rec.Header().Clone()
+			header.Del("Set-Cookie")
+			resp := response{status: rec.status, header: header, body: rec.body.Bytes()}
+			if opts.TTL > 0 {
+				resp.expires = time.Now().Add(opts.TTL).UnixNano()
+			}
+			_ = cache.Set(key, resp.encode())
+		})
+	}
+}
+
+// shared reports if response could be served to every client.
+func shared(h http.Header) bool {
+	if len(h["Vary"]) > 0 {
+		return false
+	}
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if name := strings.TrimSpace(strings.SplitN(d, "=", 2)[0]); name == "private" || name == "no-store" || name == "no-cache" {
+				return false
+			}
+			if strings.ReplaceAll(d, " ", "") == "max-age=0" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// recorder passes response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+type response struct {
+	expires int64
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+func (resp *response) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range resp.header {
+		h[k] = v
+	}
+	w.WriteHeader(resp.status)
+	_, _ = w.Write(resp.body)
+}
+
+// encode serializes response as: expires, status, number of header values followed by name/value pairs and body.
+// All integers are varints and all strings are length prefixed.
+func (resp *response) encode() []byte {
+	buf := make([]byte, 0, len(resp.body)+256)
+	buf = appendVarint(buf, resp.expires)
+	buf = appendUvarint(buf, uint64(resp.status))
+	n := 0
+	for _, v := range resp.header {
+		n += len(v)
+	}
+	buf = appendUvarint(buf, uint64(n))
+	for k, vs := range resp.header {
+		for _, v := range vs {
+			buf = appendString(buf, k)
+			buf = appendString(buf, v)
+		}
+	}
+	return append(buf, resp.body...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func decode(data []byte) (*response, error) {
+	d := decoder{buf: data}
+	resp := &response{header: make(http.Header)}
+	resp.expires = d.varint()
+	resp.status = int(d.uvarint())
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		k, v := d.string(), d.string()
+		resp.header[k] = append(resp.header[k], v)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	resp.body = d.buf
+	return resp, nil
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorruptedResponse
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorruptedResponse
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	l := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < l {
+		d.err = errCorruptedResponse
+		return ""
+	}
+	s := string(d.buf[:l])
+	d.buf = d.buf[l:]
+	return s
+}
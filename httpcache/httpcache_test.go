@@ -0,0 +1,218 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rupor-github/bigcache/v3"
+)
+
+func countingHandler(calls *int, cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "text/plain")
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("hello " + r.URL.Path))
+	})
+}
+
+func newTestCache(t *testing.T) *bigcache.BigCache {
+	cache, err := bigcache.NewBigCache(bigcache.DefaultConfig(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestResponseIsServedFromCache(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{TTL: time.Minute})(countingHandler(&calls, ""))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/path", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("want: 200; got: %d", rr.Code)
+		}
+		if rr.Body.String() != "hello /path" {
+			t.Errorf("want: %q; got: %q", "hello /path", rr.Body.String())
+		}
+		if rr.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("want: text/plain; got: %q", rr.Header().Get("Content-Type"))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("want: 1 downstream call; got: %d", calls)
+	}
+}
+
+func TestNoStoreResponseIsNotCached(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{})(countingHandler(&calls, "private, no-store"))
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+	}
+	if calls != 2 {
+		t.Errorf("want: 2 downstream calls; got: %d", calls)
+	}
+}
+
+func TestResponsesForSingleClientAreNotCached(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name, header, value string
+	}{
+		{"private", "Cache-Control", "private"},
+		{"private with fields", "Cache-Control", `private="Set-Cookie", max-age=60`},
+		{"no-cache", "Cache-Control", "public, no-cache"},
+		{"max-age=0", "Cache-Control", "max-age=0"},
+		{"vary", "Vary", "Accept-Encoding"},
+		{"vary any", "Vary", "*"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			h := NewHTTPCache(newTestCache(t), Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set(tc.header, tc.value)
+				_, _ = w.Write([]byte("hello"))
+			}))
+
+			for i := 0; i < 2; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+			}
+			if calls != 2 {
+				t.Errorf("want: 2 downstream calls; got: %d", calls)
+			}
+		})
+	}
+}
+
+func TestCookiesAreNotCached(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Set-Cookie", "session=first")
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	h.ServeHTTP(first, httptest.NewRequest("GET", "/path", nil))
+	h.ServeHTTP(second, httptest.NewRequest("GET", "/path", nil))
+
+	if calls != 1 {
+		t.Errorf("want: 1 downstream call; got: %d", calls)
+	}
+	if first.Header().Get("Set-Cookie") != "session=first" {
+		t.Errorf("want: cookie for the first client; got: %q", first.Header().Get("Set-Cookie"))
+	}
+	if second.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want: no cookie from cache; got: %q", second.Header().Get("Set-Cookie"))
+	}
+	if second.Header().Get("Content-Type") != "text/plain" || second.Body.String() != "hello" {
+		t.Errorf("want: cached response; got: %q %q", second.Header().Get("Content-Type"), second.Body.String())
+	}
+}
+
+func TestAuthorizedRequestsBypassCache(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{})(countingHandler(&calls, ""))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("GET", "/path", nil)
+		r.Header.Set("Authorization", "Bearer token")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if calls != 3 {
+		t.Errorf("want: 3 downstream calls; got: %d", calls)
+	}
+}
+
+func TestHostsDoNotShareResponses(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("hello " + r.Host))
+	}))
+
+	for i := 0; i < 2; i++ {
+		for _, host := range []string{"a.example.com", "b.example.com"} {
+			r := httptest.NewRequest("GET", "/path", nil)
+			r.Host = host
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if rr.Body.String() != "hello "+host {
+				t.Errorf("want: %q; got: %q", "hello "+host, rr.Body.String())
+			}
+		}
+	}
+	if calls != 2 {
+		t.Errorf("want: 2 downstream calls; got: %d", calls)
+	}
+}
+
+func TestOnlyGetIsCached(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{})(countingHandler(&calls, ""))
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/path", nil))
+	}
+	if calls != 2 {
+		t.Errorf("want: 2 downstream calls; got: %d", calls)
+	}
+}
+
+func TestExpiredResponseIsReloaded(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := NewHTTPCache(newTestCache(t), Options{TTL: time.Millisecond})(countingHandler(&calls, ""))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+	time.Sleep(5 * time.Millisecond)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/path", nil))
+
+	if calls != 2 {
+		t.Errorf("want: 2 downstream calls; got: %d", calls)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	resp := &response{expires: 42, status: 200, header: http.Header{"A": {"1", "2"}}, body: []byte("body")}
+	got, err := decode(resp.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.expires != 42 || got.status != 200 || string(got.body) != "body" || len(got.header["A"]) != 2 {
+		t.Errorf("round trip mismatch: %+v", got)
+	}
+	if _, err := decode([]byte{0x80}); err != errCorruptedResponse {
+		t.Errorf("want: %v; got: %v", errCorruptedResponse, err)
+	}
+}
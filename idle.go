@@ -0,0 +1,97 @@
+package bigcache
+
+import (
+	"math"
+	"sync"
+)
+
+// readLock locks shard for reading entries with given hash and returns lock to pass to readUnlock. Reads record
+// time of access when Config.IdleTimeout is set, which changes entry header, so shard is write locked then.
+func (s *cacheShard) readLock(hash uint64) *sync.RWMutex {
+	if s.idleTimeout > 0 {
+		s.Lock()
+		return nil
+	}
+	l := s.stripe(hash)
+	l.RLock()
+	return l
+}
+
+func (s *cacheShard) readUnlock(l *sync.RWMutex) {
+	if l == nil {
+		s.Unlock()
+		return
+	}
+	l.RUnlock()
+}
+
+// touchWithoutLock records current time as time of the last access to the entry.
+// NOTE: shard must be write locked.
+func (s *cacheShard) touchWithoutLock(ref qref) {
+	current, ts := s.clock.epoch(), s.entries.getTS(ref)
+	var access uint64
+	if current > ts {
+		access = current - ts
+	}
+	if access > math.MaxUint32 {
+		access = math.MaxUint32
+	}
+	ref.touch(s.entries.array, uint32(access))
+}
+
+// idleCursor is position in shard queue where scan for idle entries continues after shard lock was released.
+type idleCursor struct {
+	ref        qref
+	offset     int    // position of the entry from queue head
+	popped     uint64 // number of entries popped from queue when cursor was saved
+	expansions int64  // number of queue reallocations when cursor was saved, they could reorder entries
+	valid      bool
+}
+
+// expireIdle evicts up to cleanupBatchSize entries which were not accessed for longer than idle timeout, reporting
+// whether the whole queue was scanned. Scan continues where previous call stopped. As idle entries are removed
+// from the middle of the queue their space is reclaimed when they reach its head, like for deleted entries.
+func (s *cacheShard) expireIdle(current uint64) bool {
+
+	s.Lock()
+	defer s.Unlock()
+
+	start, offset := s.entries.head, 0
+	if c := s.idle; c.valid && c.expansions == s.entries.expansions && s.entries.popped-c.popped <= uint64(c.offset) {
+		// entries popped since cursor was saved were before it, so it still points at the same entry
+		start, offset = c.ref, c.offset-int(s.entries.popped-c.popped)
+	}
+	s.idle = idleCursor{}
+	scanned := 0
+	s.entries.walkFrom(start, offset, func(r qref, i int) bool {
+		if s.cleanupBatchSize > 0 && scanned >= s.cleanupBatchSize {
+			s.idle = idleCursor{ref: r, offset: i, popped: s.entries.popped, expansions: s.entries.expansions, valid: true}
+			return false
+		}
+		scanned++
+		if hash := s.entries.getHash(r); hash != 0 && s.indexed(hash, r) {
+			s.expireIdleEntryWithoutLock(r, hash, current)
+		}
+		return true
+	})
+	return !s.idle.valid
+}
+
+// expireIdleEntryWithoutLock evicts live entry if it was not accessed for longer than idle timeout.
+func (s *cacheShard) expireIdleEntryWithoutLock(r qref, hash uint64, current uint64) {
+	lastAccess := s.entries.getTS(r) + uint64(r.access(s.entries.array))
+	if !olderThan(current, lastAccess, s.idleTimeout) {
+		return
+	}
+	if err := s.entries.delete(r); err != nil {
+		return
+	}
+	s.unindex(hash, r)
+	s.removedWithoutLock(r, hash, Expired)
+	if s.wal != nil {
+		// unlike expiration by timestamp this could not be reproduced when log is replayed
+		if err := s.wal.appendDelete(s.entries.getKey(r), hash); err != nil {
+			s.logger.Errorf("unable to log idle entry eviction: %v", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+//go:build bigcache_full_header
+// +build bigcache_full_header
+
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	var expired []string
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		IdleTimeout:        10 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		OnExpired: func(ce *CacheEntry) {
+			expired = append(expired, string(ce.Key))
+		},
+	}, clock)
+	cache.Set("read", []byte("value"))
+	cache.Set("peeked", []byte("value"))
+	cache.Set("idle", []byte("value"))
+
+	// when
+	clock.set(8)
+	cache.Get("read")
+	cache.Peek("peeked")
+	clock.set(15)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, []string{"peeked", "idle"}, expired)
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, true, cache.Has("read"))
+
+	// when
+	clock.set(19)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, []string{"peeked", "idle", "read"}, expired)
+	assertEqual(t, 0, cache.Len())
+	assertEqual(t, int64(3), cache.Stats().EvictedExpired)
+}
+
+func TestIdleExpirationInBatches(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 0}
+	var expired []string
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		IdleTimeout:        10 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		CleanupBatchSize:   2,
+		OnExpired: func(ce *CacheEntry) {
+			expired = append(expired, string(ce.Key))
+		},
+	}, clock)
+	for i := 0; i < 6; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	clock.set(8)
+	cache.Get("key5")
+	clock.set(15)
+	shard := cache.shards[0]
+
+	// when
+	done := shard.expireIdle(clock.epoch())
+
+	// then
+	assertEqual(t, false, done)
+	assertEqual(t, []string{"key0", "key1"}, expired)
+
+	// when - entries the scan passed are popped, so it continues from the same entry
+	shard.Lock()
+	noError(t, shard.evictOldest(NoSpace))
+	shard.Unlock()
+	done = shard.expireIdle(clock.epoch())
+
+	// then
+	assertEqual(t, false, done)
+	assertEqual(t, []string{"key0", "key1", "key2", "key3"}, expired)
+
+	// when - entry the scan stopped at is popped, so it starts again from head
+	shard.Lock()
+	for i := 0; i < 4; i++ {
+		noError(t, shard.evictOldest(NoSpace))
+	}
+	shard.Unlock()
+	done = shard.expireIdle(clock.epoch())
+
+	// then
+	assertEqual(t, true, done)
+	assertEqual(t, []string{"key0", "key1", "key2", "key3"}, expired)
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, true, cache.Has("key5"))
+	assertEqual(t, true, cache.Verify() == nil)
+}
@@ -0,0 +1,85 @@
+package bigcache
+
+// Index maps hashes of entries to their positions in shard's queue, every shard has its own. Built-in map is used
+// unless Config.NewIndex is set, alternative implementation could trade lookup speed for lower memory overhead.
+// Index is only accessed under shard lock: Get and Range may be called concurrently by readers holding read lock,
+// so they must not modify the index, other methods are called with exclusive lock.
+// Entries with different keys sharing the hash are kept by shard itself, index holds at most one position per hash.
+type Index interface {
+	// Get returns position stored for hash.
+	Get(hash uint64) (pos int, found bool)
+	// Put stores position for hash replacing the previous one.
+	Put(hash uint64, pos int)
+	// Delete removes position stored for hash.
+	Delete(hash uint64)
+	// Len returns number of hashes stored.
+	Len() int
+	// Range calls f for every stored hash until f returns false.
+	Range(f func(hash uint64, pos int) bool)
+}
+
+// newIndex creates index for shard, nil means built-in map is used.
+func newIndex(config Config) Index {
+	if config.NewIndex == nil {
+		return nil
+	}
+	return config.NewIndex(config.initialShardSize())
+}
+
+// Shard index accessors below use built-in map directly unless alternative index is configured, so the default
+// path does not pay for dynamic dispatch.
+
+func (s *cacheShard) indexGet(hash uint64) (qref, bool) {
+	if s.custom != nil {
+		pos, found := s.custom.Get(hash)
+		return qref(pos), found
+	}
+	ref, found := s.hashmap[hash]
+	return ref, found
+}
+
+func (s *cacheShard) indexPut(hash uint64, ref qref) {
+	if s.custom != nil {
+		s.custom.Put(hash, ref.idx())
+		return
+	}
+	s.hashmap[hash] = ref
+}
+
+func (s *cacheShard) indexDelete(hash uint64) {
+	if s.custom != nil {
+		s.custom.Delete(hash)
+		return
+	}
+	delete(s.hashmap, hash)
+}
+
+func (s *cacheShard) indexLen() int {
+	if s.custom != nil {
+		return s.custom.Len()
+	}
+	return len(s.hashmap)
+}
+
+func (s *cacheShard) indexRange(f func(hash uint64, ref qref) bool) {
+	if s.custom != nil {
+		s.custom.Range(func(hash uint64, pos int) bool {
+			return f(hash, qref(pos))
+		})
+		return
+	}
+	for hash, ref := range s.hashmap {
+		if !f(hash, ref) {
+			return
+		}
+	}
+}
+
+// indexReset replaces index with an empty one.
+func (s *cacheShard) indexReset(config Config) {
+	if s.custom = newIndex(config); s.custom != nil {
+		s.hashmap = nil
+		return
+	}
+	s.hashmap = make(map[uint64]qref, config.initialShardSize())
+}
@@ -0,0 +1,108 @@
+package bigcache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// sliceIndex keeps positions sorted by hash, it is slow but proves that shards do not depend on built-in map.
+type sliceIndex struct {
+	hashes []uint64
+	pos    []int
+}
+
+func (x *sliceIndex) find(hash uint64) (int, bool) {
+	i := sort.Search(len(x.hashes), func(i int) bool { return x.hashes[i] >= hash })
+	return i, i < len(x.hashes) && x.hashes[i] == hash
+}
+
+func (x *sliceIndex) Get(hash uint64) (int, bool) {
+	if i, found := x.find(hash); found {
+		return x.pos[i], true
+	}
+	return 0, false
+}
+
+func (x *sliceIndex) Put(hash uint64, pos int) {
+	i, found := x.find(hash)
+	if found {
+		x.pos[i] = pos
+		return
+	}
+	x.hashes = append(x.hashes[:i], append([]uint64{hash}, x.hashes[i:]...)...)
+	x.pos = append(x.pos[:i], append([]int{pos}, x.pos[i:]...)...)
+}
+
+func (x *sliceIndex) Delete(hash uint64) {
+	if i, found := x.find(hash); found {
+		x.hashes = append(x.hashes[:i], x.hashes[i+1:]...)
+		x.pos = append(x.pos[:i], x.pos[i+1:]...)
+	}
+}
+
+func (x *sliceIndex) Len() int {
+	return len(x.hashes)
+}
+
+func (x *sliceIndex) Range(f func(hash uint64, pos int) bool) {
+	for i, hash := range x.hashes {
+		if !f(hash, x.pos[i]) {
+			return
+		}
+	}
+}
+
+func TestCustomIndex(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var created []*sliceIndex
+	cache, _ := NewBigCache(Config{
+		Shards:             2,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+		NewIndex: func(capacity int) Index {
+			x := &sliceIndex{hashes: make([]uint64, 0, capacity), pos: make([]int, 0, capacity)}
+			created = append(created, x)
+			return x
+		},
+	})
+
+	// when - all keys share the hash, so they are chained
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	cache.Delete("key0")
+	cache.Set("key1", []byte("replaced"))
+	value, err := cache.Get("key1")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("replaced"), value)
+	assertEqual(t, 9, cache.Len())
+	assertEqual(t, 2, len(created))
+	assertEqual(t, 1, created[1].Len()+created[0].Len())
+	assertEqual(t, true, cache.Verify() == nil)
+	var keys int
+	cache.Range(func(ce *CacheEntry) error {
+		keys++
+		return nil
+	})
+	assertEqual(t, 9, keys)
+
+	// when
+	cache.Reset()
+	cache.Set("key", []byte("value"))
+	value, err = cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, 4, len(created))
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, true, cache.shards[0].hashmap == nil)
+}
@@ -0,0 +1,40 @@
+package bigcache
+
+import "sync/atomic"
+
+// entryLimit counts entries of all shards to enforce Config.GlobalMaxEntries. Nil limit counts nothing.
+type entryLimit struct {
+	count int64
+	max   int64
+}
+
+func newEntryLimit(max int) *entryLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &entryLimit{max: int64(max)}
+}
+
+func (l *entryLimit) add(n int) {
+	if l == nil {
+		return
+	}
+	atomic.AddInt64(&l.count, int64(n))
+}
+
+func (l *entryLimit) exceeded() bool {
+	return l != nil && atomic.LoadInt64(&l.count) > l.max
+}
+
+// enforceLimitWithoutLock evicts the oldest entries while cache holds more entries than Config.GlobalMaxEntries.
+// Entry just stored under ref is never evicted, so limit could be exceeded when shard has no other entries.
+func (s *cacheShard) enforceLimitWithoutLock(ref qref) {
+	for s.limit.exceeded() {
+		if oldest, err := s.entries.oldest(); err != nil || oldest == ref {
+			return
+		}
+		if s.evictOldest(NoSpace) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// skewedHasher maps every key to the first of 16 shards.
+type skewedHasher struct{}
+
+func (skewedHasher) Sum64(key string) uint64 {
+	return fnv64a{}.Sum64(key) << 4
+}
+
+func TestGlobalMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             16,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 16,
+		MaxEntrySize:       256,
+		GlobalMaxEntries:   10,
+		Hasher:             skewedHasher{},
+	})
+
+	// when
+	for i := 0; i < 20; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+
+	// then
+	assertEqual(t, 10, cache.Len())
+	assertEqual(t, int64(10), cache.Stats().EvictedNoSpace)
+	_, err := cache.Get("key9")
+	assertEqual(t, true, err != nil)
+	_, err = cache.Get("key10")
+	noError(t, err)
+
+	// when
+	cache.Delete("key10")
+	cache.Set("key10", []byte("value"))
+	cache.Set("key10", []byte("replaced"))
+
+	// then
+	assertEqual(t, 10, cache.Len())
+	assertEqual(t, int64(10), cache.Stats().EvictedNoSpace)
+
+	// when
+	cache.Reset()
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+
+	// then
+	assertEqual(t, 10, cache.Len())
+}
+
+func TestGlobalMaxEntriesAfterResize(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 16,
+		MaxEntrySize:       256,
+		GlobalMaxEntries:   10,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+
+	// when
+	noError(t, cache.Resize(2))
+	cache.Set("key10", []byte("value"))
+
+	// then
+	assertEqual(t, 10, cache.Len())
+}
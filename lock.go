@@ -0,0 +1,47 @@
+package bigcache
+
+import "sync"
+
+// shardLock is a read-write lock of the shard. When striped, readers of entries lock only one of the stripes
+// selected by hash of the key, so they do not share single reader counter, while writers lock all of them.
+type shardLock struct {
+	sync.RWMutex
+	stripes []lockStripe // empty unless Config.ReadLockStripes is set
+}
+
+// lockStripe is padded to occupy its own cache line.
+type lockStripe struct {
+	sync.RWMutex
+	_ [40]byte
+}
+
+func newShardLock(stripes int) shardLock {
+	if stripes <= 1 {
+		return shardLock{}
+	}
+	return shardLock{stripes: make([]lockStripe, stripes)}
+}
+
+// Lock locks shard for writing, excluding readers of all stripes.
+func (l *shardLock) Lock() {
+	l.RWMutex.Lock()
+	for i := range l.stripes {
+		l.stripes[i].Lock()
+	}
+}
+
+// Unlock unlocks shard locked for writing.
+func (l *shardLock) Unlock() {
+	for i := range l.stripes {
+		l.stripes[i].Unlock()
+	}
+	l.RWMutex.Unlock()
+}
+
+// stripe returns lock readers of entries with given hash use.
+func (l *shardLock) stripe(hash uint64) *sync.RWMutex {
+	if len(l.stripes) == 0 {
+		return &l.RWMutex
+	}
+	return &l.stripes[hash%uint64(len(l.stripes))].RWMutex
+}
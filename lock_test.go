@@ -0,0 +1,94 @@
+package bigcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardLockStripes(t *testing.T) {
+	t.Parallel()
+
+	// given
+	single := newShardLock(1)
+	striped := newShardLock(4)
+
+	// then
+	assertEqual(t, &single.RWMutex, single.stripe(7))
+	assertEqual(t, &striped.stripes[3].RWMutex, striped.stripe(7))
+	assertEqual(t, &striped.stripes[0].RWMutex, striped.stripe(8))
+}
+
+func TestShardLockWriterExcludesStripeReaders(t *testing.T) {
+	t.Parallel()
+
+	// given
+	l := newShardLock(4)
+	reader := l.stripe(2)
+	reader.RLock()
+	locked := make(chan struct{})
+
+	// when
+	go func() {
+		l.Lock()
+		close(locked)
+		l.Unlock()
+	}()
+
+	// then
+	select {
+	case <-locked:
+		t.Fatal("writer locked shard while stripe was read locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	reader.RUnlock()
+	<-locked
+}
+
+func TestReadLockStripesKeepEntriesConsistent(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             2,
+		ReadLockStripes:    8,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 1000,
+		MaxEntrySize:       64,
+	})
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		cache.Set(keys[i], []byte(keys[i]))
+	}
+
+	// when
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := keys[(i*7+w)%len(keys)]
+				if i%10 == 0 {
+					cache.Set(key, []byte(key))
+					continue
+				}
+				if data, err := cache.Get(key); err != nil || string(data) != key {
+					errs <- fmt.Errorf("get %q: %q, %v", key, data, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	// then
+	for err := range errs {
+		t.Error(err)
+	}
+	assertEqual(t, len(keys), cache.Len())
+}
@@ -3,6 +3,7 @@ package bigcache
 import (
 	"log"
 	"os"
+	"sync"
 )
 
 // Logger interface.
@@ -10,6 +11,15 @@ type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// LeveledLogger is optional extension of Logger. When Config.Logger implements it, cache reports its messages
+// with appropriate severity, otherwise everything goes to Printf.
+type LeveledLogger interface {
+	Logger
+	Debugf(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
 // this is a safeguard, breaking on compile time in case log.Logger does not implement Logger interface.
 var _ Logger = &log.Logger{}
 
@@ -17,6 +27,31 @@ type nopLogger struct{}
 
 func (*nopLogger) Printf(_ string, _ ...interface{}) {}
 
+// printfLogger turns any Logger into LeveledLogger ignoring severity.
+type printfLogger struct {
+	Logger
+}
+
+func (l printfLogger) Debugf(format string, v ...interface{}) {
+	l.Printf(format, v...)
+}
+
+func (l printfLogger) Warnf(format string, v ...interface{}) {
+	l.Printf(format, v...)
+}
+
+func (l printfLogger) Errorf(format string, v ...interface{}) {
+	l.Printf(format, v...)
+}
+
+// leveled returns LeveledLogger for provided logger.
+func leveled(l Logger) LeveledLogger {
+	if ll, ok := l.(LeveledLogger); ok {
+		return ll
+	}
+	return printfLogger{l}
+}
+
 // NopLogger returns `empty` logger with no output.
 func newNopLogger() Logger {
 	return &nopLogger{}
@@ -26,3 +61,35 @@ func newNopLogger() Logger {
 func DefaultLogger() Logger {
 	return log.New(os.Stdout, "", log.LstdFlags)
 }
+
+// rateLimiter allows up to limit events per clock second and counts suppressed ones.
+type rateLimiter struct {
+	sync.Mutex
+	limit      int
+	second     uint64
+	count      int
+	suppressed int
+}
+
+// allow reports if event could be logged now, along with number of events suppressed since last allowed one.
+// Negative limit suppresses everything, 0 allows everything.
+func (l *rateLimiter) allow(now uint64) (bool, int) {
+	if l.limit == 0 {
+		return true, 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if now != l.second {
+		l.second, l.count = now, 0
+	}
+	if l.limit < 0 || l.count >= l.limit {
+		l.suppressed++
+		return false, 0
+	}
+	l.count++
+	suppressed := l.suppressed
+	l.suppressed = 0
+	return true, suppressed
+}
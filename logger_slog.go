@@ -0,0 +1,45 @@
+//go:build go1.21
+// +build go1.21
+
+package bigcache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// slogLogger adapts *slog.Logger to LeveledLogger.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns Logger which sends formatted messages to provided structured logger. It implements
+// LeveledLogger, so messages are logged with their severity, Printf is logged at Info level.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+func (s *slogLogger) log(level slog.Level, format string, v ...interface{}) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	s.l.Log(ctx, level, fmt.Sprintf(format, v...))
+}
+
+func (s *slogLogger) Printf(format string, v ...interface{}) {
+	s.log(slog.LevelInfo, format, v...)
+}
+
+func (s *slogLogger) Debugf(format string, v ...interface{}) {
+	s.log(slog.LevelDebug, format, v...)
+}
+
+func (s *slogLogger) Warnf(format string, v ...interface{}) {
+	s.log(slog.LevelWarn, format, v...)
+}
+
+func (s *slogLogger) Errorf(format string, v ...interface{}) {
+	s.log(slog.LevelError, format, v...)
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+// +build go1.21
+
+package bigcache
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var b bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	// when
+	l.Printf("allocated %d bytes", 42)
+	leveled(l).Debugf("collision %q", "key")
+	leveled(l).Errorf("failed %s", "badly")
+
+	// then
+	out := b.String()
+	assertEqual(t, true, strings.Contains(out, `level=INFO msg="allocated 42 bytes"`))
+	assertEqual(t, false, strings.Contains(out, "collision"))
+	assertEqual(t, true, strings.Contains(out, `level=ERROR msg="failed badly"`))
+}
@@ -0,0 +1,160 @@
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type mockedLeveledLogger struct {
+	mockedLogger
+	debug []string
+}
+
+func (ml *mockedLeveledLogger) Debugf(format string, v ...interface{}) {
+	ml.debug = append(ml.debug, format)
+}
+
+func (ml *mockedLeveledLogger) Warnf(format string, v ...interface{}) {}
+
+func (ml *mockedLeveledLogger) Errorf(format string, v ...interface{}) {}
+
+func TestLeveledLoggerIsUsedForCollisions(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ml := &mockedLeveledLogger{}
+	cache, _ := NewBigCache(Config{
+		Shards:             16,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+		Logger:             ml,
+	})
+
+	// when
+	cache.Set("liquid", []byte("value"))
+	cache.Set("costarring", []byte("value 2"))
+	cache.Get("liquid")
+
+	// then
+	assertEqual(t, []string{"Collision detected. Both %q and %q have the same hash %x"}, ml.debug)
+	assertEqual(t, "", ml.lastFormat)
+}
+
+func TestPlainLoggerIsLeveled(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ml := &mockedLogger{}
+	l := leveled(ml)
+
+	// when
+	l.Warnf("warning %d", 1)
+
+	// then
+	assertEqual(t, "warning %d", ml.lastFormat)
+	assertEqual(t, []interface{}{1}, ml.lastArgs)
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	// given
+	l := rateLimiter{limit: 2}
+
+	// when
+	ok1, _ := l.allow(1)
+	ok2, _ := l.allow(1)
+	ok3, _ := l.allow(1)
+	ok4, _ := l.allow(1)
+	ok5, suppressed := l.allow(2)
+
+	// then
+	assertEqual(t, []bool{true, true, false, false, true}, []bool{ok1, ok2, ok3, ok4, ok5})
+	assertEqual(t, 2, suppressed)
+}
+
+func TestCollisionLoggingCanBeLimited(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	ml := &mockedLeveledLogger{}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Hasher:             hashStub(5),
+		Logger:             ml,
+		CollisionLogRate:   1,
+	}, &clock)
+	cache.Set("liquid", []byte("value"))
+
+	// when
+	for i := 0; i < 5; i++ {
+		cache.Get("costarring")
+	}
+	clock.set(1)
+	cache.Get("costarring")
+
+	// then
+	assertEqual(t, 2, len(ml.debug))
+	assertEqual(t, "Collision detected. Both %q and %q have the same hash %x (%d more collisions were not logged)", ml.debug[1])
+	assertEqual(t, int64(6), cache.Stats().Collisions)
+
+	// when
+	cache.shards[0].collisions.limit = -1
+	cache.Get("costarring")
+
+	// then
+	assertEqual(t, 2, len(ml.debug))
+	assertEqual(t, int64(7), cache.Stats().Collisions)
+}
+
+func TestQueueAllocationsAreLoggedAboveThreshold(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ml := &mockedLeveledLogger{}
+	queue := newBytesQueue(64, 0, ml)
+	queue.logAbove = 500
+
+	// when
+	queue.push(&CacheEntry{Key: []byte("key"), Data: make([]byte, 100)})
+
+	// then
+	assertEqual(t, 0, len(ml.debug))
+
+	// when
+	queue.push(&CacheEntry{Key: []byte("key"), Data: make([]byte, 400)})
+
+	// then
+	assertEqual(t, []string{"Allocated new queue in %s; Capacity: %d \n"}, ml.debug)
+	assertEqual(t, "", ml.lastFormat)
+}
+
+func TestQueueAllocationsAreNotLoggedByDefault(t *testing.T) {
+	t.Parallel()
+
+	// given
+	ml := &mockedLogger{}
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         5 * time.Second,
+		MaxEntriesInWindow: 1,
+		MaxEntrySize:       16,
+		Logger:             ml,
+	})
+
+	// when
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), make([]byte, 100))
+	}
+
+	// then
+	assertEqual(t, true, cache.Stats().Reallocations > 0)
+	assertEqual(t, "", ml.lastFormat)
+}
@@ -0,0 +1,45 @@
+package bigcache
+
+import "errors"
+
+// Merge stores every live entry of other cache in this one. When key is present in both caches onConflict is
+// called with both values and its result is stored, with nil onConflict entries of other cache win.
+// Entries keep their tags. Entries stored by SetHashed, which have no keys, keep their hashes and are given to
+// onConflict with empty key, keys of other entries are hashed with this cache's Hasher.
+// Entries are copied out of other cache under its locks, so onConflict runs without holding any of them.
+func (c *BigCache) Merge(other *BigCache, onConflict func(key string, mine, theirs []byte) []byte) error {
+	return other.Range(func(ce *CacheEntry) error {
+		key, hashedKey, theirs := string(ce.Key), ce.Hash, ce.Data
+		if key != "" {
+			if err := c.validate(key); err != nil {
+				return err
+			}
+			hashedKey = c.hash.Sum64(key)
+		}
+		if onConflict != nil {
+			mine, err := c.get(key, hashedKey)
+			if err == nil {
+				theirs = onConflict(key, mine, theirs)
+			} else if !errors.Is(err, ErrEntryNotFound) {
+				return err
+			}
+		}
+		return c.setWithTag(key, hashedKey, theirs, ce.Tag)
+	})
+}
+
+// setWithTag is SetWithTag for already validated key with its hash, empty key stores entry as SetHashed does.
+func (c *BigCache) setWithTag(key string, hashedKey uint64, entry []byte, tag uint16) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	var k []byte
+	if key != "" {
+		k = []byte(key)
+	}
+	shard := c.getShard(hashedKey)
+	return c.opError("set", key, hashedKey, shard.setWithTag(k, hashedKey, entry, tag))
+}
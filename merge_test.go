@@ -0,0 +1,87 @@
+package bigcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeResolvesConflicts(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	other, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("mine", []byte("1"))
+	cache.Set("both", []byte("2"))
+	other.Set("theirs", []byte("3"))
+	other.Set("both", []byte("4"))
+
+	// when
+	err := cache.Merge(other, func(key string, mine, theirs []byte) []byte {
+		assertEqual(t, "both", key)
+		return append(append(mine, '+'), theirs...)
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, 3, cache.Len())
+	assertEqual(t, 2, other.Len())
+	for key, expected := range map[string]string{"mine": "1", "both": "2+4", "theirs": "3"} {
+		value, err := cache.Get(key)
+		noError(t, err)
+		assertEqual(t, []byte(expected), value)
+	}
+}
+
+func TestMergeWithoutConflictResolver(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	other, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("mine"))
+	other.Set("key", []byte("theirs"))
+
+	// when
+	err := cache.Merge(other, nil)
+	value, _ := cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("theirs"), value)
+}
+
+func TestMergeKeepsHashedAndTaggedEntries(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	other, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.SetHashed(42, []byte("mine"))
+	other.SetHashed(42, []byte("theirs"))
+	other.SetHashed(43, []byte("hashed"))
+	other.SetWithTag("tagged", []byte("value"), 7)
+	var conflicts []string
+
+	// when
+	err := cache.Merge(other, func(key string, mine, theirs []byte) []byte {
+		conflicts = append(conflicts, key)
+		return append(append(mine, '+'), theirs...)
+	})
+
+	// then
+	noError(t, err)
+	assertEqual(t, []string{""}, conflicts)
+	assertEqual(t, 3, cache.Len())
+	value, err := cache.GetHashed(42)
+	noError(t, err)
+	assertEqual(t, []byte("mine+theirs"), value)
+	value, err = cache.GetHashed(43)
+	noError(t, err)
+	assertEqual(t, []byte("hashed"), value)
+	value, info, err := cache.GetWithInfo("tagged")
+	noError(t, err)
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, uint16(7), info.Tag)
+}
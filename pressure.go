@@ -0,0 +1,88 @@
+package bigcache
+
+import (
+	"runtime"
+	"time"
+)
+
+const defaultPressureEvictFraction = 0.1
+
+// MemoryPressureHandler configures watchdog which sheds cache entries when process memory usage is too high,
+// even before HardMaxCacheSize is reached.
+// NOTE: evicting entries does not return memory already allocated by shards to the system, instead it makes room
+// for new entries, so shards do not have to grow.
+type MemoryPressureHandler struct {
+	// Threshold is memory usage in bytes above which entries are evicted.
+	Threshold uint64
+	// Interval between memory usage checks.
+	Interval time.Duration
+	// EvictFraction is a part of entries evicted from every shard on each check when memory usage is above threshold.
+	// Default value is 0.1.
+	EvictFraction float64
+	// Reporter returns current memory usage in bytes, by default heap usage from runtime.ReadMemStats is used.
+	Reporter func() uint64
+}
+
+func heapInUse() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func (c *BigCache) watchMemoryPressure(h MemoryPressureHandler) {
+	if h.Reporter == nil {
+		h.Reporter = heapInUse
+	}
+	if h.EvictFraction <= 0 || h.EvictFraction > 1 {
+		h.EvictFraction = defaultPressureEvictFraction
+	}
+
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if h.Reporter() > h.Threshold {
+				c.evictFraction(h.EvictFraction)
+			}
+		case <-c.close:
+			return
+		}
+	}
+}
+
+// EvictOldest evicts up to n the oldest entries from every shard, returning number of entries evicted.
+// Nothing is evicted from frozen cache.
+func (c *BigCache) EvictOldest(n int) int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return 0
+	}
+	var evicted int
+	for _, shard := range c.shards {
+		evicted += shard.evict(func(int) int { return n })
+	}
+	return evicted
+}
+
+func (c *BigCache) evictFraction(fraction float64) int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return 0
+	}
+	var evicted int
+	for _, shard := range c.shards {
+		evicted += shard.evict(func(live int) int {
+			n := int(float64(live) * fraction)
+			if n == 0 && live > 0 {
+				n = 1
+			}
+			return n
+		})
+	}
+	return evicted
+}
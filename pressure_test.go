@@ -0,0 +1,76 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEvictOldest(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+	cache.Delete("key1")
+
+	// when
+	evicted := cache.EvictOldest(3)
+
+	// then
+	assertEqual(t, 3, evicted)
+	assertEqual(t, 6, cache.Len())
+	assertEqual(t, int64(3), cache.Stats().EvictedNoSpace)
+	for i := 0; i < 4; i++ {
+		_, err := cache.Get(fmt.Sprintf("key%d", i))
+		assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	}
+	_, err := cache.Get("key4")
+	noError(t, err)
+}
+
+func TestMemoryPressureHandler(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var usage uint64
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+		MemoryPressureHandler: &MemoryPressureHandler{
+			Threshold:     1000,
+			Interval:      10 * time.Millisecond,
+			EvictFraction: 0.5,
+			Reporter:      func() uint64 { return atomic.LoadUint64(&usage) },
+		},
+	})
+	defer cache.Close()
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("value"))
+	}
+
+	// when
+	time.Sleep(50 * time.Millisecond)
+
+	// then
+	assertEqual(t, 100, cache.Len())
+
+	// when
+	atomic.StoreUint64(&usage, 2000)
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreUint64(&usage, 0)
+
+	// then
+	assertEqual(t, true, cache.Len() < 50)
+}
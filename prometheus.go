@@ -0,0 +1,61 @@
+package bigcache
+
+import (
+	"fmt"
+	"io"
+)
+
+const defaultMetricsPrefix = "bigcache"
+
+// WritePrometheus writes statistics counters in Prometheus text exposition format, so they could be served
+// without client library, i.e. by bare http.HandlerFunc. Metric names start with prefix, "bigcache" by default.
+func (s Stats) WritePrometheus(w io.Writer, prefix string) error {
+	m := metricsWriter{w: w, prefix: metricsPrefix(prefix)}
+	m.write("hits_total", "counter", "Number of successfully found keys.", s.Hits)
+	m.write("misses_total", "counter", "Number of not found keys.", s.Misses)
+	m.write("delete_hits_total", "counter", "Number of successfully deleted keys.", s.DelHits)
+	m.write("delete_misses_total", "counter", "Number of not deleted keys.", s.DelMisses)
+	m.write("collisions_total", "counter", "Number of happened key-collisions.", s.Collisions)
+	m.write("evicted_expired_total", "counter", "Number of entries evicted due to expiration.", s.EvictedExpired)
+	m.write("evicted_nospace_total", "counter", "Number of entries evicted due to absence of free space.", s.EvictedNoSpace)
+	m.write("reclaimed_tombstones_total", "counter", "Number of deleted entries which space was reclaimed.", s.ReclaimedTombstones)
+	m.write("reallocations_total", "counter", "Number of shard memory reallocations.", s.Reallocations)
+	m.write("written_bytes_total", "counter", "Number of data bytes stored.", s.BytesWritten)
+	m.write("read_bytes_total", "counter", "Number of data bytes copied out of the cache.", s.BytesRead)
+	return m.err
+}
+
+// WritePrometheus writes cache statistics along with number of entries and capacity gauges in Prometheus
+// text exposition format, see Stats.WritePrometheus.
+func (c *BigCache) WritePrometheus(w io.Writer, prefix string) error {
+	if err := c.Stats().WritePrometheus(w, prefix); err != nil {
+		return err
+	}
+	m := metricsWriter{w: w, prefix: metricsPrefix(prefix)}
+	m.write("entries", "gauge", "Number of entries in cache.", int64(c.Len()))
+	m.write("capacity_bytes", "gauge", "Amount of bytes allocated by cache shards.", int64(c.Capacity()))
+	m.write("overwritten_bytes", "gauge", "Amount of bytes taken by replaced entries not reclaimed yet.", int64(c.OverwrittenBytes()))
+	return m.err
+}
+
+func metricsPrefix(prefix string) string {
+	if prefix == "" {
+		return defaultMetricsPrefix
+	}
+	return prefix
+}
+
+// metricsWriter remembers first write error, so metrics could be written without checking every call.
+type metricsWriter struct {
+	w      io.Writer
+	prefix string
+	err    error
+}
+
+func (m *metricsWriter) write(name, kind, help string, value int64) {
+	if m.err != nil {
+		return
+	}
+	name = m.prefix + "_" + name
+	_, m.err = fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
@@ -0,0 +1,76 @@
+package bigcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("old"))
+	cache.Set("key", []byte("value"))
+	cache.Get("key")
+	cache.Get("missing")
+	var buf bytes.Buffer
+
+	// when
+	err := cache.WritePrometheus(&buf, "app_cache")
+
+	// then
+	noError(t, err)
+	values := make(map[string]int64)
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case fields[0] == "#" && fields[1] == "TYPE":
+			types[fields[2]] = fields[3]
+		case fields[0] == "#":
+		default:
+			assertEqual(t, 2, len(fields))
+			value, err := strconv.ParseInt(fields[1], 10, 64)
+			noError(t, err)
+			values[fields[0]] = value
+		}
+	}
+	assertEqual(t, 14, len(values))
+	for _, name := range []string{"hits_total", "misses_total", "delete_hits_total", "delete_misses_total",
+		"collisions_total", "evicted_expired_total", "evicted_nospace_total", "reclaimed_tombstones_total",
+		"reallocations_total",
+		"written_bytes_total", "read_bytes_total"} {
+		assertEqual(t, "counter", types["app_cache_"+name])
+	}
+	assertEqual(t, "gauge", types["app_cache_entries"])
+	assertEqual(t, "gauge", types["app_cache_capacity_bytes"])
+	assertEqual(t, "gauge", types["app_cache_overwritten_bytes"])
+	assertEqual(t, int64(1), values["app_cache_hits_total"])
+	assertEqual(t, int64(1), values["app_cache_misses_total"])
+	assertEqual(t, int64(1), values["app_cache_entries"])
+	assertEqual(t, int64(cache.Capacity()), values["app_cache_capacity_bytes"])
+	assertEqual(t, int64((&CacheEntry{Key: []byte("key"), Data: []byte("old")}).Size()), values["app_cache_overwritten_bytes"])
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWritePrometheusReturnsWriteError(t *testing.T) {
+	t.Parallel()
+
+	// when
+	err := Stats{}.WritePrometheus(failingWriter{}, "")
+
+	// then
+	assertEqual(t, "write failed", err.Error())
+}
@@ -0,0 +1,40 @@
+package bigcache
+
+// reclaimBatchSize is a maximum number of deleted entries single shard reclaims per run, it bounds lock hold time.
+const reclaimBatchSize = 64
+
+// reclaimTombstones reclaims space of deleted entries at the head of every shard's queue, returning number of
+// entries reclaimed. Nothing is reclaimed from frozen cache.
+func (c *BigCache) reclaimTombstones() int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return 0
+	}
+	var reclaimed int
+	for _, shard := range c.shards {
+		reclaimed += shard.reclaimTombstones(reclaimBatchSize)
+	}
+	return reclaimed
+}
+
+// reclaimTombstones pops up to limit deleted entries from the head of the queue, stopping at the first live one.
+func (s *cacheShard) reclaimTombstones(limit int) int {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var reclaimed int
+	for ; reclaimed < limit; reclaimed++ {
+		oldest, err := s.entries.oldest()
+		if err != nil || s.entries.getHash(oldest) != 0 {
+			break
+		}
+		if _, err := s.entries.pop(); err != nil {
+			break
+		}
+		s.reclaimed(oldest)
+	}
+	return reclaimed
+}
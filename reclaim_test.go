@@ -0,0 +1,113 @@
+package bigcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReclaimTombstones(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Set(key, []byte("value"))
+	}
+	cache.Delete("a")
+	cache.Delete("b")
+	cache.Delete("d")
+
+	// when
+	single := cache.shards[0].reclaimTombstones(1)
+	rest := cache.reclaimTombstones()
+	geometry, _ := cache.ShardGeometry(0)
+
+	// then
+	assertEqual(t, 1, single)
+	assertEqual(t, 1, rest)
+	assertEqual(t, int64(2), cache.Stats().ReclaimedTombstones)
+	assertEqual(t, 2, geometry.Count) // live entry stops reclaiming
+	assertEqual(t, 1, cache.Len())
+
+	// when
+	cache.Delete("c")
+	cache.Freeze()
+	frozen := cache.reclaimTombstones()
+
+	// then
+	assertEqual(t, 0, frozen)
+}
+
+func TestReclaimInterval(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		ReclaimInterval:    time.Millisecond,
+	})
+	defer cache.Close()
+
+	// when
+	cache.Set("key", []byte("value"))
+	cache.Delete("key")
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Stats().ReclaimedTombstones == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	geometry, _ := cache.ShardGeometry(0)
+
+	// then
+	assertEqual(t, int64(1), cache.Stats().ReclaimedTombstones)
+	assertEqual(t, 0, geometry.Count)
+}
+
+func TestOverwrittenEntriesKeepSpaceUntilReclaimed(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:                    1,
+		LifeWindow:                time.Minute,
+		MaxEntriesInWindow:        10,
+		MaxEntrySize:              256,
+		InitialShardCapacityBytes: 1024,
+	})
+	size := (&CacheEntry{Key: []byte("key"), Data: []byte("value")}).Size()
+	capacity := cache.Capacity()
+
+	// when - every set writes new entry at the tail of the queue, old one becomes tombstone
+	for i := 0; i < 100; i++ {
+		cache.Set("key", []byte("value"))
+	}
+
+	// then
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, 99*size, cache.OverwrittenBytes())
+	assertEqual(t, true, cache.Capacity() > capacity)
+
+	// when
+	reclaimed := cache.shards[0].reclaimTombstones(1000)
+
+	// then
+	assertEqual(t, 99, reclaimed)
+	assertEqual(t, 0, cache.OverwrittenBytes())
+	assertEqual(t, int64(99), cache.Stats().ReclaimedTombstones)
+	value, _ := cache.Get("key")
+	assertEqual(t, []byte("value"), value)
+
+	// when
+	cache.Set("key", []byte("other"))
+	cache.Reset()
+
+	// then
+	assertEqual(t, 0, cache.OverwrittenBytes())
+}
@@ -0,0 +1,72 @@
+package bigcache
+
+import "sync"
+
+const defaultRefreshAheadThreshold = 0.1
+
+// RefreshAhead configures asynchronous refresh of entries which are about to expire, so hot keys are kept warm
+// and readers do not see latency spikes when entries expire.
+type RefreshAhead struct {
+	// Threshold is a part of LifeWindow: when Get hits entry whose remaining life time is below it, entry is
+	// refreshed in background while current value is returned. Default value is 0.1.
+	// NOTE: cache has a one second resolution, so window shorter than a second is rounded down.
+	Threshold float64
+	// Loader returns fresh value for the key. Only one refresh runs for a key at a time, when Loader returns
+	// error entry is left untouched.
+	Loader func(key string) ([]byte, error)
+}
+
+// refreshSet keeps keys being refreshed. Zero value is ready to use.
+type refreshSet struct {
+	sync.Mutex
+	keys map[string]struct{}
+}
+
+// acquire reports if key was not being refreshed and marks it as such.
+func (s *refreshSet) acquire(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	if s.keys == nil {
+		s.keys = make(map[string]struct{})
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+func (s *refreshSet) release(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.keys, key)
+}
+
+// refreshAheadWindow returns remaining life time in seconds below which entries are refreshed.
+func (c Config) refreshAheadWindow() uint64 {
+	if c.RefreshAhead == nil {
+		return 0
+	}
+	threshold := c.RefreshAhead.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultRefreshAheadThreshold
+	}
+	return uint64(threshold * c.LifeWindow.Seconds())
+}
+
+// refreshAhead starts background refresh of the key when entry stored at ts is close enough to expiration.
+func (c *BigCache) refreshAhead(key string, ts uint64) {
+	lifeWindow := uint64(c.config.LifeWindow.Seconds())
+	if c.config.RefreshAhead.Loader == nil || !olderThan(c.clock.epoch(), ts, lifeWindow-c.refreshWindow) || !c.refreshing.acquire(key) {
+		return
+	}
+	go func() {
+		defer c.refreshing.release(key)
+
+		if data, err := c.config.RefreshAhead.Loader(key); err == nil {
+			_ = c.Set(key, data)
+		}
+	}()
+}
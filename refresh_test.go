@@ -0,0 +1,60 @@
+package bigcache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var loads int32
+	release := make(chan struct{})
+	clock := &mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		RefreshAhead: &RefreshAhead{
+			Threshold: 0.2,
+			Loader: func(key string) ([]byte, error) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+				return []byte("fresh"), nil
+			},
+		},
+	}, clock)
+	cache.Set("key", []byte("stale"))
+
+	// when - far from expiration
+	clock.set(105)
+	value, err := cache.Get("key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("stale"), value)
+	assertEqual(t, int32(0), atomic.LoadInt32(&loads))
+
+	// when - close to expiration
+	clock.set(109)
+	first, _ := cache.Get("key")
+	second, _ := cache.Get("key")
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for {
+		if value, _ = cache.Peek("key"); string(value) == "fresh" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// then
+	assertEqual(t, []byte("stale"), first)
+	assertEqual(t, []byte("stale"), second)
+	assertEqual(t, int32(1), atomic.LoadInt32(&loads))
+}
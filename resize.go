@@ -0,0 +1,75 @@
+package bigcache
+
+// Resize changes number of shards moving every live entry into new layout. Entries keep their keys, values and
+// timestamps, shard statistics are preserved in totals. New number of shards must be a power of two.
+// NOTE: all cache operations are blocked while entries are being moved, and for a short time cache needs memory for
+// both layouts, so this is a heavy operation which should be performed rarely, preferably when load is low.
+// When shard size is limited by HardMaxCacheSize and new layout has less room for entries some of them could be
+// evicted in the process, they are reported as evicted with NoSpace reason.
+func (c *BigCache) Resize(newShards int) error {
+	if newShards <= 0 || !isPowerOfTwo(newShards) {
+		return ErrInvalidShardsNumber
+	}
+
+	c.layout.Lock()
+	defer c.layout.Unlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	if newShards == len(c.shards) {
+		return nil
+	}
+
+	config := c.shardConfig()
+	config.Shards = newShards
+
+	// entries are counted again as they are moved into new shards
+	limit := newEntryLimit(config.GlobalMaxEntries)
+	shards := make([]*cacheShard, newShards)
+	for i := range shards {
+		shard, err := initNewShard(i, config, c.clock, c.events, c.wal, limit)
+		if err != nil {
+			return err
+		}
+		shards[i] = shard
+	}
+	mask := uint64(newShards - 1)
+	index := func(hash uint64) int {
+		if c.selector == nil {
+			return int(hash & mask)
+		}
+		return c.selector.Shard(hash, newShards)
+	}
+
+	for _, old := range c.shards {
+		old.Lock()
+		// walking in queue order keeps entries ordered by timestamp in new shards
+		old.live(func(r qref) bool {
+			ce, err := old.entries.get(r)
+			if err != nil {
+				return true
+			}
+			// entry is copied into new queue by push, so there is no need to copy it here
+			if err := shards[index(ce.Hash)].pushWithoutLock(ce); err != nil {
+				// entry does not fit into new shard, it is still in the old one to be reported from
+				old.removedWithoutLock(r, ce.Hash, NoSpace)
+			}
+			return true
+		})
+		c.resized.add(old.getStats())
+		if c.config.Storage != nil {
+			// entries were moved, so memory of old queue is not needed anymore
+			if err := old.releaseWithoutLock(c.shardConfig()); err != nil {
+				old.logger.Errorf("unable to release shard memory: %v", err)
+			}
+		}
+		old.Unlock()
+	}
+
+	c.shards = shards
+	c.limit = limit
+	c.shardMask = mask
+	c.maxShardSize = uint32(config.maximumShardSizeInBytes())
+	return nil
+}
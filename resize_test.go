@@ -0,0 +1,200 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResizeKeepsAllEntries(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := mockedClock{value: 0}
+	cache, _ := newBigCache(Config{
+		Shards:             256,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 10000,
+		MaxEntrySize:       32,
+	}, &clock)
+	keys := 10000
+	for i := 0; i < keys; i++ {
+		clock.set(uint64(i / 1000))
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	cache.Delete("key0")
+	cache.Get("key1")
+
+	// when
+	err := cache.Resize(1024)
+
+	// then
+	noError(t, err)
+	assertEqual(t, 1024, len(cache.shards))
+	assertEqual(t, keys-1, cache.Len())
+	for i := 1; i < keys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		value, err := cache.Get(key)
+		noError(t, err)
+		assertEqual(t, []byte(fmt.Sprintf("value%d", i)), value)
+		cache.GetWithProcessing(key, func(ce *CacheEntry) error {
+			assertEqual(t, uint64(i/1000), ce.TS)
+			return nil
+		})
+	}
+	stats := cache.Stats()
+	assertEqual(t, int64(2*(keys-1)+1), stats.Hits)
+	assertEqual(t, int64(1), stats.DelHits)
+
+	// when - entries are still expired in order
+	clock.set(15)
+	cache.cleanUp(clock.epoch())
+
+	// then
+	assertEqual(t, 5000, cache.Len())
+}
+
+func TestResizeReportsEntriesNotFittingNewShards(t *testing.T) {
+	t.Parallel()
+
+	// given
+	var evicted []string
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		HardMaxCacheSize:   1,
+		OnRemove: func(ce *CacheEntry, reason RemoveReason) {
+			assertEqual(t, NoSpace, reason)
+			evicted = append(evicted, string(ce.Key))
+		},
+	})
+	cache.Set("big", make([]byte, 600*1024))
+	cache.Set("small", []byte("value"))
+
+	// when
+	err := cache.Resize(2)
+
+	// then
+	noError(t, err)
+	assertEqual(t, []string{"big"}, evicted)
+	assertEqual(t, 1, cache.Len())
+	assertEqual(t, int64(1), cache.Stats().EvictedNoSpace)
+	_, err = cache.Get("big")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
+
+func TestResizeInvalidNumberOfShards(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(time.Second))
+
+	// then
+	assertEqual(t, ErrInvalidShardsNumber, cache.Resize(0))
+	assertEqual(t, ErrInvalidShardsNumber, cache.Resize(1000))
+}
+
+func TestResizeWithConcurrentOperations(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             16,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 1000,
+		MaxEntrySize:       32,
+	})
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	written := make([]int, 4)
+
+	// when
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					written[w] = i
+					return
+				default:
+				}
+				key := fmt.Sprintf("key%d-%d", w, i%100)
+				cache.Set(key, []byte(key))
+				if value, err := cache.Get(key); err == nil && string(value) != key {
+					t.Errorf("got %q for %q", value, key)
+				}
+			}
+		}(w)
+	}
+	for _, n := range []int{64, 4, 256, 16} {
+		noError(t, cache.Resize(n))
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	// then
+	expected := 0
+	for w, n := range written {
+		for i := 0; i < n && i < 100; i++ {
+			key := fmt.Sprintf("key%d-%d", w, i)
+			value, err := cache.Get(key)
+			noError(t, err)
+			assertEqual(t, []byte(key), value)
+			expected++
+		}
+	}
+	assertEqual(t, expected, cache.Len())
+}
+
+func TestResizeDoesNotRaceWithConfigReaders(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		CleanWindow:        time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       32,
+		NegativeTTL:        time.Minute,
+		UniqueMembers:      true,
+	})
+	defer cache.Close()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// when
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := fmt.Sprintf("key%d", i%10)
+			_, _ = cache.GetOrLoad(key, func() ([]byte, error) { return nil, ErrEntryNotFound })
+			_ = cache.AddToSet("set", []byte(key))
+			_ = cache.Healthy()
+		}
+	}()
+	for _, n := range []int{16, 2, 4, 8} {
+		noError(t, cache.Resize(n))
+	}
+	close(stop)
+	wg.Wait()
+
+	// then - configuration is left intact, layout is kept separately
+	assertEqual(t, 4, cache.config.Shards)
+	assertEqual(t, 8, len(cache.shards))
+	noError(t, cache.Reset())
+	assertEqual(t, 0, cache.Len())
+}
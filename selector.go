@@ -0,0 +1,26 @@
+package bigcache
+
+// ShardSelector maps hashed key to index of the shard in [0, numShards) range.
+type ShardSelector interface {
+	Shard(hash uint64, numShards int) int
+}
+
+// consistentSelector implements jump consistent hash (see https://arxiv.org/abs/1406.2294).
+// When number of shards grows from n to m only (m-n)/m keys change their shard.
+type consistentSelector struct{}
+
+// NewConsistentShardSelector returns ShardSelector based on consistent hashing. It is slower than default
+// selector, but moves minimal number of keys between shards when number of shards changes.
+func NewConsistentShardSelector() ShardSelector {
+	return consistentSelector{}
+}
+
+func (consistentSelector) Shard(hash uint64, numShards int) int {
+	var b, j int64 = -1, 0
+	for j < int64(numShards) {
+		b = j
+		hash = hash*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+	}
+	return int(b)
+}
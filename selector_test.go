@@ -0,0 +1,78 @@
+package bigcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDefaultShardSelection(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             16,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	})
+
+	// when
+	before := cache.shardIndex(0xF5)
+	noError(t, cache.Resize(1))
+	after := cache.shardIndex(0xF5)
+
+	// then
+	assertEqual(t, 5, before)
+	assertEqual(t, 0, after)
+}
+
+func TestConsistentSelectorMovesMinimalKeys(t *testing.T) {
+	t.Parallel()
+
+	// given
+	s := NewConsistentShardSelector()
+	h := newDefaultHasher()
+	keys, moved := 10000, 0
+
+	// when
+	for i := 0; i < keys; i++ {
+		hash := h.Sum64(fmt.Sprintf("key%d", i))
+		from, to := s.Shard(hash, 256), s.Shard(hash, 512)
+		assertEqual(t, true, from >= 0 && from < 256)
+		assertEqual(t, true, to >= 0 && to < 512)
+		if from != to {
+			assertEqual(t, true, to >= 256)
+			moved++
+		}
+	}
+
+	// then - about a half of the keys should move
+	assertEqual(t, true, moved > keys*4/10 && moved < keys*6/10)
+}
+
+func TestCacheWithConsistentSelector(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             16,
+		LifeWindow:         time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		ShardSelector:      NewConsistentShardSelector(),
+	})
+
+	// when
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	// then
+	for i := 0; i < 100; i++ {
+		value, err := cache.Get(fmt.Sprintf("key%d", i))
+		noError(t, err)
+		assertEqual(t, []byte(fmt.Sprintf("value%d", i)), value)
+	}
+	assertEqual(t, 100, cache.Len())
+}
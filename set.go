@@ -0,0 +1,133 @@
+package bigcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Sets keep multiple values under one key. Entry data is a sequence of members, each prefixed with its length
+// encoded as unsigned varint, so an entry of the set is regular entry and is expired, evicted and persisted as any
+// other one. Sets are modified under single shard lock, which makes every operation atomic.
+
+// AddToSet adds value to the set kept under the key, creating the set when there is no entry for the key. When
+// Config.UniqueMembers is set value which is already a member is not added again.
+// Existing entry which is not a set is reported with ErrMalformedSet and left intact.
+func (c *BigCache) AddToSet(key string, value []byte) error {
+	unique := c.config.UniqueMembers
+	return c.updateSet("add to set", key, func(old []byte, found bool) ([]byte, bool, error) {
+		if found && unique {
+			member, err := hasMember(old, value)
+			if err != nil || member {
+				return nil, false, errUnchanged(err)
+			}
+		} else if found {
+			if err := validSet(old); err != nil {
+				return nil, false, err
+			}
+		}
+		data := make([]byte, 0, len(old)+binary.MaxVarintLen64+len(value))
+		return appendMember(append(data, old...), value), true, nil
+	})
+}
+
+// RemoveFromSet removes all members equal to value from the set kept under the key, the entry is removed with the
+// last member. Removing value which is not a member does nothing, ErrEntryNotFound is returned when there is no
+// entry for the key.
+func (c *BigCache) RemoveFromSet(key string, value []byte) error {
+	return c.updateSet("remove from set", key, func(old []byte, found bool) ([]byte, bool, error) {
+		if !found {
+			return nil, false, ErrEntryNotFound
+		}
+		var data []byte
+		removed := false
+		err := rangeMembers(old, func(member []byte) {
+			if bytes.Equal(member, value) {
+				removed = true
+				return
+			}
+			data = appendMember(data, member)
+		})
+		if err != nil || !removed {
+			return nil, false, errUnchanged(err)
+		}
+		return data, len(data) > 0, nil
+	})
+}
+
+// Members returns members of the set kept under the key in order they were added.
+func (c *BigCache) Members(key string) ([][]byte, error) {
+	data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var members [][]byte
+	// members reference copy returned by Get, so they do not need to be copied again
+	if err := rangeMembers(data, func(member []byte) {
+		members = append(members, member[:len(member):len(member)])
+	}); err != nil {
+		return nil, c.opError("members", key, c.hash.Sum64(key), err)
+	}
+	return members, nil
+}
+
+// errSetUnchanged is returned by set modifications which leave the entry as it is, so it is not stored again. It is
+// never reported to caller.
+var errSetUnchanged = errors.New("set is not changed")
+
+// errUnchanged turns nil error into errSetUnchanged.
+func errUnchanged(err error) error {
+	if err == nil {
+		return errSetUnchanged
+	}
+	return err
+}
+
+func (c *BigCache) updateSet(op, key string, f func(old []byte, found bool) ([]byte, bool, error)) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	shard := c.getShard(hashedKey)
+	if err := shard.update([]byte(key), hashedKey, f); err != nil && err != errSetUnchanged {
+		return c.opError(op, key, hashedKey, err)
+	}
+	return nil
+}
+
+func appendMember(data, member []byte) []byte {
+	var size [binary.MaxVarintLen64]byte
+	data = append(data, size[:binary.PutUvarint(size[:], uint64(len(member)))]...)
+	return append(data, member...)
+}
+
+// rangeMembers calls f for every member of the set.
+func rangeMembers(data []byte, f func(member []byte)) error {
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return ErrMalformedSet
+		}
+		f(data[n : n+int(size)])
+		data = data[n+int(size):]
+	}
+	return nil
+}
+
+func validSet(data []byte) error {
+	return rangeMembers(data, func([]byte) {})
+}
+
+func hasMember(data, value []byte) (bool, error) {
+	found := false
+	err := rangeMembers(data, func(member []byte) {
+		found = found || bytes.Equal(member, value)
+	})
+	return found, err
+}
@@ -0,0 +1,151 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setConfig() Config {
+	return Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 100,
+		MaxEntrySize:       256,
+	}
+}
+
+func TestSetMembers(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(setConfig())
+
+	// when
+	noError(t, cache.AddToSet("tag", []byte("item1")))
+	noError(t, cache.AddToSet("tag", []byte("")))
+	noError(t, cache.AddToSet("tag", []byte("item2")))
+	noError(t, cache.AddToSet("tag", []byte("item1")))
+	members, err := cache.Members("tag")
+
+	// then
+	noError(t, err)
+	assertEqual(t, [][]byte{[]byte("item1"), []byte(""), []byte("item2"), []byte("item1")}, members)
+
+	// when
+	noError(t, cache.RemoveFromSet("tag", []byte("item1")))
+	noError(t, cache.RemoveFromSet("tag", []byte("absent")))
+	members, err = cache.Members("tag")
+
+	// then
+	noError(t, err)
+	assertEqual(t, [][]byte{[]byte(""), []byte("item2")}, members)
+}
+
+func TestSetUniqueMembers(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config := setConfig()
+	config.UniqueMembers = true
+	cache, _ := NewBigCache(config)
+
+	// when
+	for i := 0; i < 3; i++ {
+		noError(t, cache.AddToSet("tag", []byte("item1")))
+		noError(t, cache.AddToSet("tag", []byte("item2")))
+	}
+	members, err := cache.Members("tag")
+
+	// then
+	noError(t, err)
+	assertEqual(t, [][]byte{[]byte("item1"), []byte("item2")}, members)
+}
+
+func TestSetIsRemovedWithLastMember(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(setConfig())
+	noError(t, cache.AddToSet("tag", []byte("item")))
+
+	// when
+	noError(t, cache.RemoveFromSet("tag", []byte("item")))
+	_, errMembers := cache.Members("tag")
+	errRemove := cache.RemoveFromSet("tag", []byte("item"))
+
+	// then
+	assertEqual(t, true, errors.Is(errMembers, ErrEntryNotFound))
+	assertEqual(t, true, errors.Is(errRemove, ErrEntryNotFound))
+	assertEqual(t, 0, cache.Len())
+}
+
+func TestSetRejectsMalformedEntry(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(setConfig())
+	noError(t, cache.Set("key", []byte{0x7f, 'a'}))
+
+	// when
+	errAdd := cache.AddToSet("key", []byte("item"))
+	errRemove := cache.RemoveFromSet("key", []byte("a"))
+	_, errMembers := cache.Members("key")
+
+	// then
+	assertEqual(t, true, errors.Is(errAdd, ErrMalformedSet))
+	assertEqual(t, true, errors.Is(errRemove, ErrMalformedSet))
+	assertEqual(t, true, errors.Is(errMembers, ErrMalformedSet))
+	value, err := cache.Get("key")
+	noError(t, err)
+	assertEqual(t, []byte{0x7f, 'a'}, value)
+}
+
+func TestSetWithCipher(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(cipherConfig(t))
+
+	// when
+	noError(t, cache.AddToSet("tag", []byte("item1")))
+	noError(t, cache.AddToSet("tag", []byte("item2")))
+	noError(t, cache.RemoveFromSet("tag", []byte("item1")))
+	members, err := cache.Members("tag")
+
+	// then
+	noError(t, err)
+	assertEqual(t, [][]byte{[]byte("item2")}, members)
+}
+
+func TestSetConcurrentAdds(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       4096,
+	})
+	var wg sync.WaitGroup
+
+	// when
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_ = cache.AddToSet("tag", []byte(fmt.Sprintf("%d-%d", w, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	members, err := cache.Members("tag")
+
+	// then
+	noError(t, err)
+	assertEqual(t, 200, len(members))
+}
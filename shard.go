@@ -1,119 +1,475 @@
 package bigcache
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
-	"sync"
+	"math/bits"
 	"sync/atomic"
 )
 
 type cacheShard struct {
-	sync.RWMutex
-	hashmap    map[uint64]qref
-	entries    *bytesQueue
-	onRemove   OnRemoveCallback
-	lifeWindow uint64
-	clock      clock
-	logger     Logger
-	stats      Stats
+	shardLock
+	id               int               // index of the shard in the cache
+	hashmap          map[uint64]qref   // built-in index, nil when custom one is used
+	custom           Index             // alternative index configured by Config.NewIndex
+	chains           map[uint64][]qref // entries with different keys sharing the hash with entry in hashmap
+	overwritten      int               // number of bytes taken by entries replaced by newer ones and not reclaimed yet
+	chained          int               // number of references kept in chains
+	negatives        int               // number of indexed markers of absent keys stored by SetMiss
+	entries          *bytesQueue
+	onRemove         OnRemoveCallback
+	onExpired        func(*CacheEntry)
+	onEvicted        func(*CacheEntry)
+	onDeleted        func(*CacheEntry)
+	onBeforeEvict    func(*CacheEntry, RemoveReason) bool
+	onShardFull      func(*CacheEntry, int)
+	lifeWindow       uint64
+	negativeTTL      uint64
+	maxKeyLength     int
+	watermark        int
+	idleTimeout      uint64     // entries not read for that long are evicted by cleanup, 0 means no limit
+	maxEvictionScan  int        // maximum number of entries single allocation evicts or skips, 0 means unlimited
+	cleanupBatchSize int        // maximum number of entries cleanup removes under single lock, 0 means unlimited
+	idle             idleCursor // position where scan for idle entries continues
+	clock            clock
+	logger           LeveledLogger
+	collisions       rateLimiter
+	events           *eventBroker
+	filter           *bloomFilter
+	protected        *protectedSet  // protected segment of SLRU, nil for other eviction policies
+	hot              *hotKeys       // nil when Config.HotKeysPerShard is not set
+	wal              *writeAheadLog // nil when write-ahead log is not configured
+	limit            *entryLimit    // shared by all shards, nil when Config.GlobalMaxEntries is not set
+	cipher           Cipher         // nil when entries are not encrypted
+	stats            Stats
+	noStats          bool // statistics are not collected
 }
 
-func (s *cacheShard) get(key string, hash uint64, f Processor) ([]byte, error) {
+func (s *cacheShard) get(key []byte, hash uint64, f Processor) ([]byte, error) {
+
+	if s.filter != nil && !s.filter.mayContain(hash) {
+		// definitely absent, no need to lock
+		s.miss()
+		return nil, ErrEntryNotFound
+	}
+
+	defer s.readUnlock(s.readLock(hash))
+
+	return s.getWithoutLock(key, hash, f, false)
+}
+
+// peek is get which does not update statistics.
+func (s *cacheShard) peek(key []byte, hash uint64) ([]byte, error) {
+
+	if s.filter != nil && !s.filter.mayContain(hash) {
+		return nil, ErrEntryNotFound
+	}
 
 	s.RLock()
 	defer s.RUnlock()
 
-	return s.getWithoutLock(key, hash, f)
+	return s.getWithoutLock(key, hash, nil, true)
 }
 
-func (s *cacheShard) getWithoutLock(key string, hash uint64, f Processor) ([]byte, error) {
-	ref, found := s.hashmap[hash]
-	if !found {
-		s.miss()
-		return nil, ErrEntryNotFound
+func (s *cacheShard) has(key []byte, hash uint64) bool {
+
+	if s.filter != nil && !s.filter.mayContain(hash) {
+		return false
 	}
-	err := s.entries.peek(ref)
+
+	s.RLock()
+	defer s.RUnlock()
+
+	_, _, err := s.resolveWithoutLock(key, hash)
+	return err == nil
+}
+
+// getWithoutLock reads entry, statistics are not updated when quiet is set.
+func (s *cacheShard) getWithoutLock(key []byte, hash uint64, f Processor, quiet bool) ([]byte, error) {
+	ref, err := s.lookupWithoutLock(key, hash, quiet)
 	if err != nil {
-		s.miss()
 		return nil, err
 	}
-	if len(key) > 0 && s.entries.collide(ref, []byte(key)) {
-		// TODO: do we actually need this print - our logger is not level'ed?
-		s.logger.Printf("Collision detected. Both %q and %q have the same hash %x", key, s.entries.getKey(ref), hash)
-		s.collision()
-		return nil, ErrEntryNotFound
-	}
-	s.hit()
 	if f != nil {
-		ce, _ := s.entries.get(ref)
+		ce, err := s.entry(ref)
+		if err != nil {
+			return nil, err
+		}
 		return nil, f(ce)
 	}
-	return s.entries.getDataCopy(ref), nil
+	data, err := s.dataCopy(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !quiet {
+		s.read(len(data))
+	}
+	return data, nil
+}
+
+// getWithInfo reads entry along with its header information.
+func (s *cacheShard) getWithInfo(key []byte, hash uint64) ([]byte, EntryInfo, error) {
+
+	defer s.readUnlock(s.readLock(hash))
+
+	ref, err := s.lookupWithoutLock(key, hash, false)
+	if err != nil {
+		return nil, EntryInfo{}, err
+	}
+	data, err := s.dataCopy(ref)
+	if err != nil {
+		return nil, EntryInfo{}, err
+	}
+	s.read(len(data))
+	return data, newEntryInfo(s.entries.getTS(ref), s.entries.getTag(ref), s.clock.epoch(), s.lifeWindow), nil
 }
 
-func (s *cacheShard) set(key string, hash uint64, entry []byte) error {
+// lookupWithoutLock finds reference to the entry updating statistics unless quiet is set.
+func (s *cacheShard) lookupWithoutLock(key []byte, hash uint64, quiet bool) (qref, error) {
+	ref, collided, err := s.resolveWithoutLock(key, hash)
+	switch {
+	case quiet:
+	case collided:
+		s.reportCollision(key, ref, hash)
+	case err != nil:
+		s.miss()
+	default:
+		s.hit()
+		s.protected.promote(ref)
+		s.hot.record(hash)
+		if s.idleTimeout > 0 {
+			s.touchWithoutLock(ref)
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+	return ref, nil
+}
+
+// resolveWithoutLock finds reference to the entry. When key collides with other key reference to entry of
+// the latter is returned along with an error.
+func (s *cacheShard) resolveWithoutLock(key []byte, hash uint64) (ref qref, collided bool, err error) {
+	ref, found, collided := s.find(key, hash)
+	if collided {
+		return ref, true, ErrEntryNotFound
+	}
+	if !found {
+		return -1, false, ErrEntryNotFound
+	}
+	if err := s.entries.peek(ref); err != nil {
+		return -1, false, err
+	}
+	if s.entries.getFlags(ref)&flagNegative != 0 {
+		if !olderThan(s.clock.epoch(), s.entries.getTS(ref), s.negativeTTL) {
+			return -1, false, ErrEntryNegativeCached
+		}
+		return -1, false, ErrEntryNotFound
+	}
+	return ref, false, nil
+}
+
+// find returns reference to the entry for the key. Entries with different keys and the same hash are chained, for
+// already hashed keys (empty key) entry stored first under the hash is returned. When hash is used by other keys only
+// collided is set and reference to one of their entries is returned.
+func (s *cacheShard) find(key []byte, hash uint64) (ref qref, found, collided bool) {
+	ref, found = s.indexGet(hash)
+	if !found || len(key) == 0 || !s.entries.collide(ref, key) {
+		return ref, found, false
+	}
+	for _, r := range s.chains[hash] {
+		if !s.entries.collide(r, key) {
+			return r, true, false
+		}
+	}
+	return ref, false, true
+}
+
+// reportCollision counts collision of the key with entry kept under the same hash and logs it unless rate limited.
+func (s *cacheShard) reportCollision(key []byte, other qref, hash uint64) {
+	s.collision()
+	if ok, suppressed := s.collisions.allow(s.clock.epoch()); ok {
+		if suppressed > 0 {
+			s.logger.Debugf("Collision detected. Both %q and %q have the same hash %x (%d more collisions were not logged)",
+				string(key), s.entries.getKey(other), hash, suppressed)
+		} else {
+			s.logger.Debugf("Collision detected. Both %q and %q have the same hash %x", string(key), s.entries.getKey(other), hash)
+		}
+	}
+}
+
+// getInto copies entry's data into dst, returning number of bytes copied or required size of dst if it is too small.
+func (s *cacheShard) getInto(key []byte, hash uint64, dst []byte) (int, error) {
+
+	if s.filter != nil && !s.filter.mayContain(hash) {
+		s.miss()
+		return 0, ErrEntryNotFound
+	}
+
+	defer s.readUnlock(s.readLock(hash))
+
+	ref, err := s.lookupWithoutLock(key, hash, false)
+	if err != nil {
+		return 0, err
+	}
+	data, err := s.plain(ref)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(dst) {
+		return len(data), ErrBufferTooSmall
+	}
+	s.read(len(data))
+	return copy(dst, data), nil
+}
+
+func (s *cacheShard) set(key []byte, hash uint64, entry []byte) error {
 
 	s.Lock()
 	defer s.Unlock()
 
-	return s.setWithoutLock(key, hash, entry)
+	return s.setWithoutLock(key, hash, entry, 0)
 }
 
-func (s *cacheShard) setWithoutLock(key string, hash uint64, entry []byte) error {
+func (s *cacheShard) setWithTag(key []byte, hash uint64, entry []byte, tag uint16) error {
 
-	current := s.clock.epoch()
+	s.Lock()
+	defer s.Unlock()
+
+	return s.setWithoutLock(key, hash, entry, tag)
+}
+
+func (s *cacheShard) setWithoutLock(key []byte, hash uint64, entry []byte, tag uint16) error {
+	ce := &CacheEntry{Hash: hash, Key: key, Data: entry, Tag: tag}
+	if entry == nil {
+		ce.flags = flagNil
+	}
+	if err := s.seal(ce); err != nil {
+		return err
+	}
+	if err := s.storeWithoutLock(ce); err != nil {
+		s.fullWithoutLock(&CacheEntry{Hash: hash, Key: key, Data: entry, Tag: tag}, err)
+		return err
+	}
+	return nil
+}
+
+// fullWithoutLock reports entry which could not be stored because shard is full to OnShardFull.
+func (s *cacheShard) fullWithoutLock(ce *CacheEntry, err error) {
+	if s.onShardFull != nil && (errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueEntryTooBig)) {
+		s.onShardFull(ce, s.id)
+	}
+}
+
+// setMiss stores marker of known to be absent key.
+func (s *cacheShard) setMiss(key []byte, hash uint64) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.storeWithoutLock(&CacheEntry{Hash: hash, Key: key, flags: flagNegative})
+}
+
+// storeWithoutLock timestamps prepared entry and puts it into the queue replacing previous entry with the same key.
+func (s *cacheShard) storeWithoutLock(ce *CacheEntry) error {
 
-	if prev, found := s.hashmap[hash]; found {
+	if len(ce.Key) > s.maxKeyLength {
+		return ErrKeyTooLong
+	}
+
+	current, hash := s.clock.epoch(), ce.Hash
+
+	s.replaceWithoutLock(ce.Key, hash)
+
+	s.expireOldestWithoutLock(current)
+
+	ce.TS = current
+
+	if err := s.pushWithoutLock(ce); err != nil {
+		return err
+	}
+	s.written(len(ce.Data))
+	s.events.publish(EventSet, ce.Key, hash, NoReason, 0)
+	if s.wal != nil {
+		return s.wal.append(ce)
+	}
+	return nil
+}
+
+// replaceWithoutLock removes previous entry for the key before new one is stored. Collision is reported when hash
+// is still used by entry with other key, new entry is chained with it then.
+func (s *cacheShard) replaceWithoutLock(key []byte, hash uint64) {
+	if prev, found, _ := s.find(key, hash); found {
 		if err := s.entries.delete(prev); err == nil {
-			delete(s.hashmap, hash)
+			s.unindex(hash, prev)
+			prev.mark(s.entries.array, flagOverwritten)
+			s.overwritten += s.entries.getSize(prev)
 		}
 	}
+	if other, found := s.indexGet(hash); found {
+		s.reportCollision(key, other, hash)
+	}
+}
 
+// expireOldestWithoutLock opportunistically evicts the oldest entry if it is expired.
+func (s *cacheShard) expireOldestWithoutLock(current uint64) {
 	if oldest, err := s.entries.oldest(); err == nil {
-		if current-s.entries.getTS(oldest) > s.lifeWindow {
+		if olderThan(current, s.entries.getTS(oldest), s.lifeWindow) {
 			_ = s.evictOldest(Expired)
 		}
 	}
+}
+
+// olderThan reports if more than window passed since ts. Timestamps from the future (i.e. after clock moved back)
+// are never older, as subtraction would wrap around.
+func olderThan(current, ts, window uint64) bool {
+	return current > ts && current-ts > window
+}
+
+// setWithProcessing reserves space for entry with data of given size and lets f to fill it in place.
+// Previous entry for the hash is replaced only when f succeeds.
+func (s *cacheShard) setWithProcessing(key []byte, hash uint64, size int, f func(dst []byte) error) error {
+
+	s.Lock()
+	defer s.Unlock()
 
-	ce := &CacheEntry{
-		TS:   current,
-		Hash: hash,
-		Key:  []byte(key),
-		Data: entry,
+	if len(key) > s.maxKeyLength {
+		return ErrKeyTooLong
+	}
+	if s.cipher != nil {
+		// data has to be encrypted, so it cannot be written into the queue directly
+		data := make([]byte, size)
+		if err := f(data); err != nil {
+			return err
+		}
+		return s.setWithoutLock(key, hash, data, 0)
 	}
 
+	current := s.clock.epoch()
+	s.expireOldestWithoutLock(current)
+
+	ce := &CacheEntry{TS: current, Hash: hash, Key: key}
+	ref, err := s.allocWithoutLock(ce.Size() + size)
+	if err != nil {
+		s.fullWithoutLock(ce, err)
+		return err
+	}
+	ref.writeHeader(s.entries.array, ce, ce.Size()+size)
+	if err := f(ref.data(s.entries.array)); err != nil {
+		// space will be reclaimed when entry reaches head of the queue
+		_ = s.entries.delete(ref)
+		return err
+	}
+
+	s.replaceWithoutLock(ce.Key, hash)
+	s.index(hash, ref)
+	s.enforceLimitWithoutLock(ref)
+	s.written(size)
+	s.events.publish(EventSet, ce.Key, hash, NoReason, 0)
+	if s.wal != nil {
+		ce, _ = s.entries.get(ref)
+		return s.wal.append(ce)
+	}
+	return nil
+}
+
+// pushWithoutLock puts entry into the queue as is, evicting the oldest entries if there is no space for it.
+func (s *cacheShard) pushWithoutLock(ce *CacheEntry) error {
+	ref, err := s.allocWithoutLock(ce.Size())
+	if err != nil {
+		return err
+	}
+	ref.write(s.entries.array, ce)
+	s.index(ce.Hash, ref)
+	s.enforceLimitWithoutLock(ref)
+	return nil
+}
+
+// allocWithoutLock reserves space in the queue evicting the oldest entries if there is not enough of it.
+func (s *cacheShard) allocWithoutLock(size int) (qref, error) {
+	if !s.entries.fits(size) {
+		// do not evict anything for entry which could never be stored
+		return -1, fmt.Errorf("new entry is bigger than max shard size: %w", ErrQueueEntryTooBig)
+	}
+	scanned := 0
 	for {
-		if ref, err := s.entries.push(ce); err == nil {
-			s.hashmap[hash] = ref
-			return nil
+		ref, err := s.entries.alloc(size)
+		if err == nil {
+			return ref, nil
 		}
-		if err := s.evictOldest(NoSpace); err != nil {
-			return fmt.Errorf("new entry is bigger than max shard size: %w", err)
+		if s.maxEvictionScan > 0 && scanned >= s.maxEvictionScan {
+			// entries popped so far are gone, so the next attempt continues where this one stopped
+			return -1, fmt.Errorf("eviction scan limit reached: %w", ErrQueueFull)
+		}
+		scanned++
+		if s.evictOldest(NoSpace) != nil {
+			// nothing left to evict
+			return -1, fmt.Errorf("new entry is bigger than max shard size: %w", err)
+		}
+		for s.watermark > 0 && s.entries.used() > s.watermark && (s.maxEvictionScan <= 0 || scanned < s.maxEvictionScan) {
+			scanned++
+			if err := s.evictOldest(NoSpace); err != nil {
+				break
+			}
 		}
 	}
 }
 
 func (s *cacheShard) cleanUp(timestamp uint64) {
+	// lock is released between batches, so other operations are not blocked until everything is removed
+	for done := false; !done; {
+		done = s.expireOldest(timestamp)
+	}
+	if s.idleTimeout > 0 {
+		for done := false; !done; {
+			done = s.expireIdle(timestamp)
+		}
+	}
+	s.hot.decay()
+}
+
+// expireOldest removes up to cleanupBatchSize expired entries reporting whether there are no expired entries left.
+func (s *cacheShard) expireOldest(timestamp uint64) bool {
 
 	s.Lock()
 	defer s.Unlock()
 
-	var oldest qref
-	var err error
-	for {
-		if oldest, err = s.entries.oldest(); err != nil {
-			break
+	for n := 0; s.cleanupBatchSize <= 0 || n < s.cleanupBatchSize; n++ {
+		oldest, err := s.entries.oldest()
+		if err != nil {
+			return true
 		}
-		if timestamp-s.entries.getTS(oldest) <= s.lifeWindow {
-			break
+		if !olderThan(timestamp, s.entries.getTS(oldest), s.lifeWindow) {
+			return true
 		}
 		if err = s.evictOldest(Expired); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// evict removes the oldest entries, count function gets number of live entries in shard and returns how many to evict.
+func (s *cacheShard) evict(count func(live int) int) int {
+
+	s.Lock()
+	defer s.Unlock()
+
+	live := s.lenWithoutLock()
+	target := live - count(live)
+	for s.lenWithoutLock() > target {
+		if err := s.evictOldest(NoSpace); err != nil {
 			break
 		}
 	}
+	return live - s.lenWithoutLock()
 }
 
 func (s *cacheShard) evictOldest(reason RemoveReason) error {
+	if reason == NoSpace && s.protected != nil && s.demoteProtectedWithoutLock() {
+		return nil
+	}
 	oldest, err := s.entries.pop()
 	if err != nil {
 		return err
@@ -121,10 +477,48 @@ func (s *cacheShard) evictOldest(reason RemoveReason) error {
 	hash := s.entries.getHash(oldest)
 	if hash == 0 {
 		// ignore explicitly deleted entries
+		s.reclaimed(oldest)
 		return nil
 	}
-	delete(s.hashmap, hash)
+	if s.onBeforeEvict != nil && s.keptWithoutLock(oldest, hash, reason) {
+		return nil
+	}
+	s.unindex(hash, oldest)
+	s.removedWithoutLock(oldest, hash, reason)
+	return nil
+}
+
+// keptWithoutLock asks OnBeforeEvict if popped entry should be kept. Kept expired entry is stored again at the tail
+// of the queue with current timestamp, entries evicted because of no space are removed regardless of the answer.
+func (s *cacheShard) keptWithoutLock(oldest qref, hash uint64, reason RemoveReason) bool {
+	ce, err := s.entry(oldest)
+	if err != nil || ce.flags&flagNegative != 0 {
+		return false
+	}
+	if !s.onBeforeEvict(ce, reason) || reason != Expired {
+		return false
+	}
+	// popped entry space could be reused by allocation
+	stored, _ := s.entries.get(oldest)
+	_ = duplicate(stored)
+	stored.TS, stored.access = s.clock.epoch(), 0
+	ref, err := s.entries.alloc(stored.Size())
+	if err != nil {
+		return false
+	}
+	ref.write(s.entries.array, stored)
+	s.unindex(hash, oldest)
+	s.index(hash, ref)
+	if s.wal != nil {
+		if err := s.wal.append(stored); err != nil {
+			s.logger.Errorf("unable to log kept entry: %v", err)
+		}
+	}
+	return true
+}
 
+// removedWithoutLock accounts for evicted entry, which is already popped from the queue, and notifies about it.
+func (s *cacheShard) removedWithoutLock(oldest qref, hash uint64, reason RemoveReason) {
 	// NOTE: User should not have a call back just to count evictions - it is expensive
 	switch reason {
 	case Expired:
@@ -137,59 +531,217 @@ func (s *cacheShard) evictOldest(reason RemoveReason) error {
 		panic("this should never happen")
 	}
 
-	if s.onRemove != nil {
-		// only allocate memory if needed
-		ce, _ := s.entries.get(oldest)
-		s.onRemove(ce, reason)
+	s.notifyRemovedWithoutLock(oldest, hash, reason)
+	s.events.publish(EventEvict, s.entries.getKey(oldest), hash, reason, s.entries.getSize(oldest))
+}
+
+// append adds chunks to the data stored under the key in a single write, entry is created when there is none.
+func (s *cacheShard) append(key []byte, hash uint64, chunks ...[]byte) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var total int
+	for _, chunk := range chunks {
+		total += len(chunk)
+	}
+	var (
+		data []byte
+		tag  uint16
+	)
+	ref, err := s.lookupWithoutLock(key, hash, false)
+	if err == nil {
+		if s.cipher == nil {
+			size := len(ref.data(s.entries.array))
+			if s.entries.grow(ref, total) {
+				tail := ref.data(s.entries.array)[size:]
+				for _, chunk := range chunks {
+					tail = tail[copy(tail, chunk):]
+				}
+				return s.storedInPlaceWithoutLock(ref, hash, total)
+			}
+		}
+		ce, err := s.entry(ref)
+		if err != nil {
+			return err
+		}
+		tag = ce.Tag
+		data = ce.CopyData(len(ce.Data) + total)
+	} else if !errors.Is(err, ErrEntryNotFound) {
+		return err
+	} else if len(chunks) == 1 {
+		return s.setWithoutLock(key, hash, chunks[0], tag)
+	} else {
+		data = make([]byte, 0, total)
+	}
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return s.setWithoutLock(key, hash, data, tag)
+}
+
+// update replaces entry with value computed by f from the current one, or removes it, under single lock. When f
+// returns error entry is left intact.
+func (s *cacheShard) update(key []byte, hash uint64, f func(old []byte, found bool) ([]byte, bool, error)) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	var (
+		old []byte
+		tag uint16
+	)
+	ref, err := s.lookupWithoutLock(key, hash, false)
+	found := err == nil
+	if found {
+		ce, err := s.entry(ref)
+		if err != nil {
+			return err
+		}
+		old, tag = ce.Data, ce.Tag
+	} else if !errors.Is(err, ErrEntryNotFound) {
+		return err
+	}
+	data, keep, err := f(old, found)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		if !found {
+			return nil
+		}
+		if err := s.delWithoutLock(hash, ref); err != nil {
+			return err
+		}
+		s.delhit()
+		return nil
+	}
+	if found && data != nil && s.cipher == nil && len(data) >= len(old) && s.entries.grow(ref, len(data)-len(old)) {
+		// data may reference old one, copy handles overlapping slices
+		copy(ref.data(s.entries.array), data)
+		return s.storedInPlaceWithoutLock(ref, hash, len(data))
+	}
+	if found && data != nil {
+		// data may reference queue buffer which could be reused while new entry is stored
+		data = append(make([]byte, 0, len(data)), data...)
+	}
+	return s.setWithoutLock(key, hash, data, tag)
+}
+
+// storedInPlaceWithoutLock timestamps entry which data was changed without moving it and reports it as stored again.
+// It is only done for the last entry in the queue, so entries stay ordered by timestamps. Written is number of bytes
+// of data actually written.
+func (s *cacheShard) storedInPlaceWithoutLock(ref qref, hash uint64, written int) error {
+	buf := s.entries.array
+	ref.restamp(buf, s.clock.epoch(), ref.flags(buf)&^flagNil)
+	s.written(written)
+	s.events.publish(EventSet, ref.key(buf), hash, NoReason, 0)
+	if s.wal != nil {
+		ce, _ := s.entries.get(ref)
+		return s.wal.append(ce)
 	}
 	return nil
 }
 
-func (s *cacheShard) append(key string, hash uint64, entry []byte) error {
+func (s *cacheShard) prepend(key []byte, hash uint64, entry []byte) error {
 
 	s.Lock()
 	defer s.Unlock()
 
-	var data []byte
-	appender := func(ce *CacheEntry) error {
-		data = append(ce.CopyData(len(ce.Data)+len(entry)), entry...)
+	var (
+		data []byte
+		tag  uint16
+	)
+	prepender := func(ce *CacheEntry) error {
+		tag = ce.Tag
+		data = append(append(make([]byte, 0, len(entry)+len(ce.Data)), entry...), ce.Data...)
 		return nil
 	}
 
-	if _, err := s.getWithoutLock(key, hash, appender); err != nil {
+	if _, err := s.getWithoutLock(key, hash, prepender, false); err != nil {
 		if !errors.Is(err, ErrEntryNotFound) {
 			return err
 		}
 		data = entry
 	}
-	return s.setWithoutLock(key, hash, data)
+	return s.setWithoutLock(key, hash, data, tag)
 }
 
-func (s *cacheShard) del(hash uint64) error {
+func (s *cacheShard) del(key []byte, hash uint64) error {
 
 	s.Lock()
 	defer s.Unlock()
 
-	ref, found := s.hashmap[hash]
+	ref, found, _ := s.find(key, hash)
 	if !found {
 		s.delmiss()
 		return ErrEntryNotFound
 	}
 
-	if err := s.entries.delete(ref); err != nil {
+	if err := s.delWithoutLock(hash, ref); err != nil {
 		s.delmiss()
 		return err
 	}
+	s.delhit()
+	return nil
+}
 
-	delete(s.hashmap, hash)
-	if s.onRemove != nil {
-		// only allocate memory if needed
-		ce, _ := s.entries.get(ref)
-		// restore hash value - it was set to 0 by deletion
-		ce.Hash = hash
-		s.onRemove(ce, Deleted)
+// delIfUnchanged removes entry only if it is still kept under the reference, i.e. was not replaced or removed
+// since reference was obtained.
+func (s *cacheShard) delIfUnchanged(hash uint64, ref qref) bool {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.indexed(hash, ref) {
+		return false
+	}
+	if err := s.delWithoutLock(hash, ref); err != nil {
+		return false
 	}
 	s.delhit()
+	return true
+}
+
+// notifyRemovedWithoutLock calls OnRemove and callback for the reason of removal, entry is read only if needed.
+func (s *cacheShard) notifyRemovedWithoutLock(ref qref, hash uint64, reason RemoveReason) {
+	var specific func(*CacheEntry)
+	switch reason {
+	case Expired:
+		specific = s.onExpired
+	case NoSpace:
+		specific = s.onEvicted
+	case Deleted:
+		specific = s.onDeleted
+	}
+	if s.onRemove == nil && specific == nil {
+		return
+	}
+	ce, err := s.entry(ref)
+	if err != nil {
+		return
+	}
+	// restore hash value - it is set to 0 by deletion
+	ce.Hash = hash
+	if s.onRemove != nil {
+		s.onRemove(ce, reason)
+	}
+	if specific != nil {
+		specific(ce)
+	}
+}
+
+func (s *cacheShard) delWithoutLock(hash uint64, ref qref) error {
+	if err := s.entries.delete(ref); err != nil {
+		return err
+	}
+
+	s.unindex(hash, ref)
+	s.notifyRemovedWithoutLock(ref, hash, Deleted)
+	s.events.publish(EventDelete, s.entries.getKey(ref), hash, Deleted, 0)
+	if s.wal != nil {
+		return s.wal.appendDelete(s.entries.getKey(ref), hash)
+	}
 	return nil
 }
 
@@ -200,25 +752,179 @@ func (s *cacheShard) getEntry(r qref, f Processor) (*CacheEntry, error) {
 	s.RLock()
 	defer s.RUnlock()
 
-	ce, err := s.entries.get(r)
+	if !s.isLiveWithoutLock(r) {
+		// reference was taken before entry was removed or shard was reset
+		return nil, ErrEntryNotFound
+	}
+	ce, err := s.entry(r)
 	if err != nil {
 		return nil, err
 	}
+	if ce.flags&flagNegative != 0 {
+		return nil, ErrEntryNotFound
+	}
 	if f != nil {
 		return ce, f(ce)
 	}
 	return ce, nil
 }
 
+// live calls f for every live entry in queue order (from the oldest to the newest), stopping when f returns false.
+// NOTE: shard lock must be held.
+func (s *cacheShard) live(f func(qref) bool) {
+	s.entries.walk(func(r qref) bool {
+		hash := s.entries.getHash(r)
+		if hash == 0 || !s.indexed(hash, r) {
+			return true
+		}
+		return f(r)
+	})
+}
+
+// isLiveWithoutLock reports if reference taken earlier still points to the start of indexed entry.
+// NOTE: shard lock must be held.
+func (s *cacheShard) isLiveWithoutLock(r qref) bool {
+	if r < 0 || r.idx()+offKeyStr > len(s.entries.array) {
+		return false
+	}
+	hash := s.entries.getHash(r)
+	return hash != 0 && s.indexed(hash, r)
+}
+
+// edgeEntry returns copy of the oldest or the newest live entry, nil when there is none.
+// NOTE: looking for the newest entry walks through the whole queue.
+func (s *cacheShard) edgeEntry(newest bool) *CacheEntry {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var edge *CacheEntry
+	s.live(func(r qref) bool {
+		ce, err := s.entry(r)
+		if err != nil || ce.flags&flagNegative != 0 {
+			return true
+		}
+		edge = ce
+		return newest
+	})
+	if edge != nil {
+		_ = duplicate(edge)
+	}
+	return edge
+}
+
+// sizeHistogram adds sizes of live entries data to histogram, see BigCache.SizeHistogram.
+func (s *cacheShard) sizeHistogram(histogram []int) []int {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	s.live(func(r qref) bool {
+		if s.entries.getFlags(r)&flagNegative != 0 {
+			return true
+		}
+		bucket := bits.Len(uint(len(r.data(s.entries.array))))
+		for len(histogram) <= bucket {
+			histogram = append(histogram, 0)
+		}
+		histogram[bucket]++
+		return true
+	})
+	return histogram
+}
+
+// largestEntries adds live entries of the shard to min-heap of n largest entries.
+func (s *cacheShard) largestEntries(largest *entrySizes, n int) {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	s.live(func(r qref) bool {
+		if s.entries.getFlags(r)&flagNegative != 0 {
+			return true
+		}
+		size := s.entries.getSize(r)
+		if len(*largest) == n && (*largest)[0].Size >= size {
+			return true
+		}
+		entry := EntrySize{Key: string(s.entries.getKey(r)), Hash: s.entries.getHash(r), Size: size}
+		if len(*largest) < n {
+			heap.Push(largest, entry)
+		} else {
+			// replace the smallest one
+			(*largest)[0] = entry
+			heap.Fix(largest, 0)
+		}
+		return true
+	})
+}
+
+// expiringBefore counts live entries which expire not later than deadline.
+func (s *cacheShard) expiringBefore(deadline uint64) int {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var count int
+	s.live(func(r qref) bool {
+		if s.entries.getTS(r)+s.lifeWindow > deadline {
+			// entries are ordered by timestamp, so there is no need to look further, unless SLRU moved some
+			return s.protected != nil
+		}
+		if s.entries.getFlags(r)&flagNegative == 0 {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// orderedRefs returns references to live entries in queue order (from the oldest to the newest).
+func (s *cacheShard) orderedRefs() []qref {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	refs := make([]qref, 0, s.lenWithoutLock())
+	s.live(func(r qref) bool {
+		refs = append(refs, r)
+		return true
+	})
+	return refs
+}
+
+// rangeLocked calls f for every live entry while holding read lock, entries reference shard's buffer directly.
+// Iteration stops on the first error returned by f.
+func (s *cacheShard) rangeLocked(f Processor) error {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var err error
+	s.live(func(r qref) bool {
+		ce, e := s.entry(r)
+		if e != nil || ce.flags&flagNegative != 0 {
+			return true
+		}
+		err = f(ce)
+		return err == nil
+	})
+	return err
+}
+
 // Used during Range only - expensive copy of entry references available in the shard's queue at the moment.
 func (s *cacheShard) copyRefs() []qref {
 
 	s.RLock()
 	defer s.RUnlock()
 
-	indices := make([]qref, 0, len(s.hashmap))
-	for _, r := range s.hashmap {
+	indices := make([]qref, 0, s.lenWithoutLock())
+	s.indexRange(func(_ uint64, r qref) bool {
 		indices = append(indices, r)
+		return true
+	})
+	for _, chain := range s.chains {
+		indices = append(indices, chain...)
 	}
 	return indices
 }
@@ -228,17 +934,127 @@ func (s *cacheShard) reset(config Config) {
 	s.Lock()
 	defer s.Unlock()
 
-	s.hashmap = make(map[uint64]qref, config.initialShardSize())
+	if s.wal != nil {
+		s.live(func(r qref) bool {
+			if err := s.wal.appendDelete(s.entries.getKey(r), s.entries.getHash(r)); err != nil {
+				s.logger.Errorf("unable to log shard reset: %v", err)
+				return false
+			}
+			return true
+		})
+	}
+	s.clearWithoutLock(config)
 	s.entries.reset()
+	s.idle = idleCursor{}
+}
+
+// release empties shard returning memory of its queue to storage.
+func (s *cacheShard) release(config Config) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.releaseWithoutLock(config)
+}
+
+func (s *cacheShard) releaseWithoutLock(config Config) error {
+	s.clearWithoutLock(config)
+	return s.entries.release()
+}
+
+// clearWithoutLock forgets all entries, queue is left for caller.
+func (s *cacheShard) clearWithoutLock(config Config) {
+	s.limit.add(-s.lenWithoutLock())
+	s.indexReset(config)
+	s.chains, s.chained = nil, 0
+	s.overwritten, s.negatives = 0, 0
+	s.protected.reset()
+	s.hot.reset()
+	if s.filter != nil {
+		s.filter.reset()
+	}
+}
+
+// index makes entry reference available for lookups. Entry for the same key must be unindexed before, entries with
+// other keys sharing the hash are kept.
+func (s *cacheShard) index(hash uint64, ref qref) {
+	s.limit.add(1)
+	if s.entries.getFlags(ref)&flagNegative != 0 {
+		s.negatives++
+	}
+	if _, found := s.indexGet(hash); found {
+		if s.chains == nil {
+			s.chains = make(map[uint64][]qref)
+		}
+		s.chains[hash] = append(s.chains[hash], ref)
+		s.chained++
+		return
+	}
+	if s.filter != nil {
+		s.filter.add(hash)
+	}
+	s.indexPut(hash, ref)
+}
+
+// unindex removes entry reference, so it could not be found anymore.
+func (s *cacheShard) unindex(hash uint64, ref qref) {
+	s.limit.add(-1)
+	if s.entries.getFlags(ref)&flagNegative != 0 {
+		s.negatives--
+	}
+	s.protected.demote(ref)
+	chain := s.chains[hash]
+	if primary, found := s.indexGet(hash); found && primary == ref {
+		if len(chain) == 0 {
+			s.indexDelete(hash)
+			if s.filter != nil {
+				s.filter.remove(hash)
+			}
+			return
+		}
+		// promote the first chained entry
+		s.indexPut(hash, chain[0])
+		ref = chain[0]
+	}
+	for i, r := range chain {
+		if r == ref {
+			chain = append(chain[:i], chain[i+1:]...)
+			s.chained--
+			break
+		}
+	}
+	if len(chain) == 0 {
+		delete(s.chains, hash)
+	} else {
+		s.chains[hash] = chain
+	}
+}
+
+// indexed reports if entry reference is available for lookups.
+func (s *cacheShard) indexed(hash uint64, ref qref) bool {
+	if primary, found := s.indexGet(hash); !found || primary == ref {
+		return found
+	}
+	for _, r := range s.chains[hash] {
+		if r == ref {
+			return true
+		}
+	}
+	return false
 }
 
+// len returns number of entries, markers of absent keys are not counted.
 func (s *cacheShard) len() int {
 
 	s.RLock()
 	defer s.RUnlock()
 
-	res := len(s.hashmap)
-	return res
+	return s.lenWithoutLock() - s.negatives
+}
+
+// lenWithoutLock returns number of indexed references including markers of absent keys.
+func (s *cacheShard) lenWithoutLock() int {
+	return s.indexLen() + s.chained
 }
 
 func (s *cacheShard) cap() int {
@@ -248,59 +1064,188 @@ func (s *cacheShard) cap() int {
 	return res
 }
 
+func (s *cacheShard) geometry() ShardGeometry {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return ShardGeometry{
+		Head:     s.entries.head.idx(),
+		Tail:     s.entries.tail.idx(),
+		Right:    s.entries.right.idx(),
+		Count:    s.entries.count,
+		Capacity: s.entries.cap(),
+	}
+}
+
+func (s *cacheShard) headroom() int {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.entries.headroom()
+}
+
+func (s *cacheShard) overwrittenBytes() int {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.overwritten
+}
+
+// indexMemory estimates number of bytes taken by shard index, including chains of entries sharing the hash.
+func (s *cacheShard) indexMemory() int {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	// hash with qref, and hash with slice header
+	return mapMemory(s.indexLen(), 16) + mapMemory(len(s.chains), 32) + s.chained*8
+}
+
 func (s *cacheShard) getStats() Stats {
+	if s.noStats {
+		return Stats{}
+	}
 	var stats = Stats{
-		Hits:           atomic.LoadInt64(&s.stats.Hits),
-		Misses:         atomic.LoadInt64(&s.stats.Misses),
-		DelHits:        atomic.LoadInt64(&s.stats.DelHits),
-		DelMisses:      atomic.LoadInt64(&s.stats.DelMisses),
-		Collisions:     atomic.LoadInt64(&s.stats.Collisions),
-		EvictedExpired: atomic.LoadInt64(&s.stats.EvictedExpired),
-		EvictedNoSpace: atomic.LoadInt64(&s.stats.EvictedNoSpace),
+		Hits:                atomic.LoadInt64(&s.stats.Hits),
+		Misses:              atomic.LoadInt64(&s.stats.Misses),
+		DelHits:             atomic.LoadInt64(&s.stats.DelHits),
+		DelMisses:           atomic.LoadInt64(&s.stats.DelMisses),
+		Collisions:          atomic.LoadInt64(&s.stats.Collisions),
+		EvictedExpired:      atomic.LoadInt64(&s.stats.EvictedExpired),
+		EvictedNoSpace:      atomic.LoadInt64(&s.stats.EvictedNoSpace),
+		ReclaimedTombstones: atomic.LoadInt64(&s.stats.ReclaimedTombstones),
+		Reallocations:       atomic.LoadInt64(&s.entries.expansions),
+		BytesWritten:        atomic.LoadInt64(&s.stats.BytesWritten),
+		BytesRead:           atomic.LoadInt64(&s.stats.BytesRead),
 	}
 	return stats
 }
 
 func (s *cacheShard) hit() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.Hits, 1)
 }
 
 func (s *cacheShard) miss() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.Misses, 1)
 }
 
+func (s *cacheShard) written(n int) {
+	if s.noStats {
+		return
+	}
+	atomic.AddInt64(&s.stats.BytesWritten, int64(n))
+}
+
+func (s *cacheShard) read(n int) {
+	if s.noStats {
+		return
+	}
+	atomic.AddInt64(&s.stats.BytesRead, int64(n))
+}
+
 func (s *cacheShard) delhit() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.DelHits, 1)
 }
 
 func (s *cacheShard) delmiss() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.DelMisses, 1)
 }
 
 func (s *cacheShard) collision() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.Collisions, 1)
 }
 
 func (s *cacheShard) expired() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.EvictedExpired, 1)
 }
 
+// reclaimed accounts for deleted entry popped from the queue.
+// NOTE: shard lock must be held.
+func (s *cacheShard) reclaimed(r qref) {
+	if s.entries.getFlags(r)&flagOverwritten != 0 {
+		s.overwritten -= s.entries.getSize(r)
+	}
+	if s.noStats {
+		return
+	}
+	atomic.AddInt64(&s.stats.ReclaimedTombstones, 1)
+}
+
 func (s *cacheShard) nospace() {
+	if s.noStats {
+		return
+	}
 	atomic.AddInt64(&s.stats.EvictedNoSpace, 1)
 }
 
-func initNewShard(config Config, clock clock) *cacheShard {
+func initNewShard(id int, config Config, clock clock, events *eventBroker, wal *writeAheadLog, limit *entryLimit) (*cacheShard, error) {
 	bytesQueueInitialCapacity := config.initialShardSize() * config.MaxEntrySize
+	if config.InitialShardCapacityBytes > 0 {
+		bytesQueueInitialCapacity = config.InitialShardCapacityBytes
+	}
 	maximumShardSizeInBytes := config.maximumShardSizeInBytes()
-	if maximumShardSizeInBytes > 0 && bytesQueueInitialCapacity > maximumShardSizeInBytes {
+	if maximumShardSizeInBytes > 0 && (bytesQueueInitialCapacity > maximumShardSizeInBytes || config.PreallocateToMax) {
 		bytesQueueInitialCapacity = maximumShardSizeInBytes
 	}
-	return &cacheShard{
-		hashmap:    make(map[uint64]qref, config.initialShardSize()),
-		entries:    newBytesQueue(bytesQueueInitialCapacity, maximumShardSizeInBytes, config.Logger),
-		onRemove:   config.OnRemove,
-		logger:     config.Logger,
-		clock:      clock,
-		lifeWindow: uint64(config.LifeWindow.Seconds()),
+	storage := config.Storage
+	if storage == nil {
+		storage = heapStorage{}
+	}
+	entries, err := newBytesQueueWithStorage(bytesQueueInitialCapacity, maximumShardSizeInBytes, config.Logger, storage)
+	if err != nil {
+		return nil, err
+	}
+	entries.logAbove = config.LogAllocationsAbove
+	shard := &cacheShard{
+		shardLock:        newShardLock(config.ReadLockStripes),
+		id:               id,
+		entries:          entries,
+		onRemove:         config.OnRemove,
+		onExpired:        config.OnExpired,
+		onEvicted:        config.OnEvicted,
+		onDeleted:        config.OnDeleted,
+		onBeforeEvict:    config.OnBeforeEvict,
+		onShardFull:      config.OnShardFull,
+		logger:           leveled(config.Logger),
+		noStats:          config.DisableStats,
+		collisions:       rateLimiter{limit: config.CollisionLogRate},
+		events:           events,
+		wal:              wal,
+		limit:            limit,
+		cipher:           config.Cipher,
+		filter:           newBloomFilter(config.BloomFilterSize),
+		protected:        newProtectedSet(config.EvictionPolicy),
+		hot:              newHotKeys(config.HotKeysPerShard),
+		clock:            clock,
+		lifeWindow:       uint64(config.LifeWindow.Seconds()),
+		negativeTTL:      uint64(config.NegativeTTL.Seconds()),
+		watermark:        config.evictionWatermarkInBytes(),
+		idleTimeout:      uint64(config.IdleTimeout.Seconds()),
+		maxEvictionScan:  config.MaxEvictionScan,
+		cleanupBatchSize: config.CleanupBatchSize,
+		maxKeyLength:     config.maxKeyLength(),
 	}
+	shard.indexReset(config)
+	return shard, nil
 }
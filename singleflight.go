@@ -0,0 +1,65 @@
+package bigcache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// loadCall is in-flight or completed loader invocation.
+type loadCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+	dups int // number of callers waiting for the result
+}
+
+// loadGroup makes sure only one loader runs for a key at a time, other callers wait for its result.
+// Zero value is ready to use.
+type loadGroup struct {
+	sync.Mutex
+	calls map[string]*loadCall
+}
+
+func (g *loadGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.Unlock()
+		c.wg.Wait()
+		return c.data, c.err
+	}
+	c := &loadCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.Unlock()
+
+	normalReturn := false
+	defer func() {
+		// waiters must be released even if loader panics or calls runtime.Goexit, they get error while panic or
+		// exit goes on in this goroutine
+		var r interface{}
+		if !normalReturn {
+			if r = recover(); r != nil {
+				c.data, c.err = nil, fmt.Errorf("%w: %v", ErrLoaderPanicked, r)
+			} else {
+				c.data, c.err = nil, fmt.Errorf("%w: runtime.Goexit was called", ErrLoaderPanicked)
+			}
+		}
+		c.wg.Done()
+
+		g.Lock()
+		delete(g.calls, key)
+		g.Unlock()
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.data, c.err = fn()
+	normalReturn = true
+	return c.data, c.err
+}
@@ -0,0 +1,89 @@
+package bigcache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// Save writes every live entry to w, so cache contents could be restored later by Load. Entries are written in the
+// same framing CacheEntry.WriteTo uses and keep their timestamps. Cache could be used while it is saved, every shard
+// is read locked only while its entries are written, so snapshot is consistent per shard.
+func (c *BigCache) Save(w io.Writer) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, shard := range c.shards {
+		if err := shard.writeLive(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Load stores entries written by Save replacing entries with the same keys. Entries keep their timestamps, so the
+// ones expired in the meantime are removed as usual. Hasher must be the same as in the cache which saved them.
+func (c *BigCache) Load(r io.Reader) error {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	if c.isFrozen() {
+		return ErrCacheFrozen
+	}
+	br := bufio.NewReader(r)
+	for {
+		var ce CacheEntry
+		n, err := ce.ReadFrom(br)
+		if err == io.EOF && n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.getShard(ce.Hash).restore(&ce); err != nil {
+			return err
+		}
+	}
+}
+
+// saveSnapshot atomically replaces snapshot file configured by Config.SnapshotPath.
+func (c *BigCache) saveSnapshot() error {
+	c.snapshot.Lock()
+	defer c.snapshot.Unlock()
+
+	path := c.config.SnapshotPath
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = c.Save(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// loadSnapshot restores cache from snapshot file configured by Config.SnapshotPath, if there is one.
+func (c *BigCache) loadSnapshot() error {
+	f, err := os.Open(c.config.SnapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Load(f)
+}
@@ -0,0 +1,85 @@
+package bigcache
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	// given
+	clock := &mockedClock{value: 100}
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+	}
+	cache, _ := newBigCache(config, clock)
+	cache.Set("key", []byte("value"))
+	cache.Set("nil", nil)
+	cache.Set("deleted", []byte("value"))
+	cache.Delete("deleted")
+	var buf bytes.Buffer
+
+	// when
+	err := cache.Save(&buf)
+	clock.set(200)
+	loaded, _ := newBigCache(config, clock)
+	loaded.Set("key", []byte("overwritten"))
+	loadErr := loaded.Load(&buf)
+
+	// then
+	noError(t, err)
+	noError(t, loadErr)
+	assertEqual(t, 2, loaded.Len())
+	value, info, _ := loaded.GetWithInfo("key")
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, uint64(100), info.Timestamp)
+	value, err = loaded.Get("nil")
+	noError(t, err)
+	assertEqual(t, []byte(nil), value)
+	_, err = loaded.Get("deleted")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+}
+
+func TestSnapshotIsSavedOnCloseAndLoaded(t *testing.T) {
+	t.Parallel()
+
+	// given
+	dir, err := ioutil.TempDir("", "bigcache-snapshot")
+	noError(t, err)
+	defer os.RemoveAll(dir)
+	config := Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		SnapshotPath:       filepath.Join(dir, "cache.snapshot"),
+		SnapshotInterval:   time.Millisecond,
+	}
+	cache, err := NewBigCache(config)
+	noError(t, err)
+	cache.Set("key", []byte("value"))
+	time.Sleep(10 * time.Millisecond)
+	cache.Set("last", []byte("value"))
+
+	// when
+	noError(t, cache.Close())
+	restored, err := NewBigCache(config)
+	noError(t, err)
+	defer restored.Close()
+
+	// then
+	assertEqual(t, 2, restored.Len())
+	value, _ := restored.Get("last")
+	assertEqual(t, []byte("value"), value)
+	_, err = os.Stat(config.SnapshotPath + ".tmp")
+	assertEqual(t, true, os.IsNotExist(err))
+}
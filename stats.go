@@ -1,5 +1,7 @@
 package bigcache
 
+import "fmt"
+
 // Stats stores cache statistics.
 type Stats struct {
 	// Hits is a number of successfully found keys
@@ -16,4 +18,57 @@ type Stats struct {
 	EvictedExpired int64 `json:"expired"`
 	// EvictedNoSpace is a number of entries evicted due to absence of free space
 	EvictedNoSpace int64 `json:"nospace"`
+	// ReclaimedTombstones is a number of deleted or overwritten entries which space was reclaimed when they reached
+	// head of the queue. Such entries keep their space until then, which is why memory use does not drop right after
+	// deletes (see also BigCache.OverwrittenBytes)
+	ReclaimedTombstones int64 `json:"reclaimed_tombstones"`
+	// Reallocations is a number of times shard's memory had to be reallocated because it was not big enough,
+	// growing value suggests that initial shard size is too small (see MaxEntriesInWindow and MaxEntrySize)
+	Reallocations int64 `json:"reallocations"`
+	// BytesWritten is a number of data bytes stored, keys and entry headers are not included
+	BytesWritten int64 `json:"bytes_written"`
+	// BytesRead is a number of data bytes copied out of the cache
+	BytesRead int64 `json:"bytes_read"`
+	// BloomFillRatio is an average part of Bloom filter counters in use, it is 0 when filter is not configured
+	BloomFillRatio float64 `json:"bloom_fill_ratio"`
+}
+
+// add sums counters, BloomFillRatio is not a counter and is left untouched.
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.DelHits += o.DelHits
+	s.DelMisses += o.DelMisses
+	s.Collisions += o.Collisions
+	s.EvictedExpired += o.EvictedExpired
+	s.EvictedNoSpace += o.EvictedNoSpace
+	s.ReclaimedTombstones += o.ReclaimedTombstones
+	s.Reallocations += o.Reallocations
+	s.BytesWritten += o.BytesWritten
+	s.BytesRead += o.BytesRead
+}
+
+// HitRatio returns part of lookups which found the key, it is 0 when there were no lookups.
+func (s Stats) HitRatio() float64 {
+	return ratio(s.Hits, s.Hits+s.Misses)
+}
+
+// EvictionRate returns number of evicted entries per recorded operation (lookups and deletions),
+// it is 0 when there were no operations.
+func (s Stats) EvictionRate() float64 {
+	return ratio(s.EvictedExpired+s.EvictedNoSpace, s.Hits+s.Misses+s.DelHits+s.DelMisses)
+}
+
+// String formats statistics as space separated key=value pairs suitable for logging.
+func (s Stats) String() string {
+	return fmt.Sprintf("hits=%d misses=%d hit_ratio=%.4f delete_hits=%d delete_misses=%d collisions=%d expired=%d nospace=%d eviction_rate=%.4f reclaimed_tombstones=%d reallocations=%d bytes_written=%d bytes_read=%d",
+		s.Hits, s.Misses, s.HitRatio(), s.DelHits, s.DelMisses, s.Collisions, s.EvictedExpired, s.EvictedNoSpace, s.EvictionRate(),
+		s.ReclaimedTombstones, s.Reallocations, s.BytesWritten, s.BytesRead)
+}
+
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
 }
@@ -0,0 +1,49 @@
+package bigcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsRatios(t *testing.T) {
+	t.Parallel()
+
+	// given
+	stats := Stats{Hits: 3, Misses: 1, DelHits: 2, DelMisses: 2, EvictedExpired: 1, EvictedNoSpace: 1}
+
+	// then
+	assertEqual(t, 0.75, stats.HitRatio())
+	assertEqual(t, 0.25, stats.EvictionRate())
+	assertEqual(t, "hits=3 misses=1 hit_ratio=0.7500 delete_hits=2 delete_misses=2 collisions=0 expired=1 nospace=1 eviction_rate=0.2500 reclaimed_tombstones=0 reallocations=0 bytes_written=0 bytes_read=0", stats.String())
+}
+
+func TestStatsRatiosWithoutOperations(t *testing.T) {
+	t.Parallel()
+
+	// given
+	stats := Stats{EvictedExpired: 1}
+
+	// then
+	assertEqual(t, 0.0, stats.HitRatio())
+	assertEqual(t, 0.0, stats.EvictionRate())
+}
+
+func TestStatsInto(t *testing.T) {
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+	cache.Set("key", []byte("value"))
+	cache.Get("key")
+	cache.Get("missing")
+	var stats Stats
+
+	// when
+	allocs := testing.AllocsPerRun(10, func() {
+		cache.StatsInto(&stats)
+	})
+
+	// then
+	assertEqual(t, 0.0, allocs)
+	assertEqual(t, cache.Stats(), stats)
+	assertEqual(t, int64(1), stats.Hits)
+	assertEqual(t, int64(1), stats.Misses)
+}
@@ -0,0 +1,29 @@
+package bigcache
+
+// Storage provides memory backing shard queues. It lets cache keep its entries outside of Go heap, i.e. in memory
+// mapped files, see NewMmapStorage. Methods are called under shard lock and may be called concurrently for
+// different shards.
+type Storage interface {
+	// Allocate returns zeroed byte slice of given size.
+	Allocate(size int) ([]byte, error)
+	// Release is called with slice returned by Allocate when cache does not use it anymore.
+	Release(buf []byte) error
+}
+
+// growingStorage is implemented by Storage which could enlarge slice keeping its contents, so queue does not
+// have to copy entries when it expands.
+type growingStorage interface {
+	// Grow returns slice of given size with contents of buf, buf must not be used afterwards.
+	Grow(buf []byte, size int) ([]byte, error)
+}
+
+// heapStorage allocates queues on Go heap, it is used by default.
+type heapStorage struct{}
+
+func (heapStorage) Allocate(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func (heapStorage) Release([]byte) error {
+	return nil
+}
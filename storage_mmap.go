@@ -0,0 +1,104 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package bigcache
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// mmapStorage keeps shard queues in shared memory mappings of files. Every mapping has its own file, which is kept
+// open, so mapping could grow with the file.
+type mmapStorage struct {
+	sync.Mutex
+	dir   string
+	files map[*byte]*os.File // files by the first byte of their mappings
+}
+
+// NewMmapStorage returns Storage keeping shard queues in memory mapped files created in dir (default directory
+// for temporary files when empty). Operating system pages them to files instead of swap, which suits caches
+// bigger than available memory. Queues grow by extending their files and mapping them again, so entries are not
+// copied. Files are removed as soon as they are created, so nothing is left behind when process exits, and mappings
+// are released by Close.
+// NOTE: cache contents do not survive restarts this way, see Config.WALPath and Config.SnapshotPath for that.
+func NewMmapStorage(dir string) Storage {
+	return &mmapStorage{dir: dir, files: make(map[*byte]*os.File)}
+}
+
+func (s *mmapStorage) Allocate(size int) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+	f, err := createTemp(s.dir, "bigcache-*.mmap")
+	if err != nil {
+		return nil, err
+	}
+	// file stays accessible through descriptor
+	_ = os.Remove(f.Name())
+
+	buf, err := mapFile(f, size)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s.Lock()
+	s.files[&buf[0]] = f
+	s.Unlock()
+	return buf, nil
+}
+
+// Grow extends file of the mapping and maps it again, contents are shared by both mappings, so old one could be
+// released right away.
+func (s *mmapStorage) Grow(buf []byte, size int) ([]byte, error) {
+	if cap(buf) == 0 {
+		return s.Allocate(size)
+	}
+	buf = buf[:cap(buf)]
+	s.Lock()
+	defer s.Unlock()
+
+	f := s.files[&buf[0]]
+	if f == nil {
+		return nil, os.ErrInvalid
+	}
+	grown, err := mapFile(f, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Munmap(buf); err != nil {
+		_ = syscall.Munmap(grown)
+		return nil, err
+	}
+	delete(s.files, &buf[0])
+	s.files[&grown[0]] = f
+	return grown, nil
+}
+
+func (s *mmapStorage) Release(buf []byte) error {
+	if cap(buf) == 0 {
+		return nil
+	}
+	buf = buf[:cap(buf)]
+	s.Lock()
+	f := s.files[&buf[0]]
+	delete(s.files, &buf[0])
+	s.Unlock()
+
+	err := syscall.Munmap(buf)
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// mapFile extends file to size and maps all of it.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	if err := f.Truncate(int64(size)); err != nil {
+		return nil, err
+	}
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package bigcache
+
+// NewMmapStorage returns Storage keeping shard queues on Go heap, as memory mapped files are not supported on this
+// platform. It lets code configuring Config.Storage build everywhere.
+func NewMmapStorage(dir string) Storage {
+	return heapStorage{}
+}
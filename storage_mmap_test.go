@@ -0,0 +1,81 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package bigcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMmapStorage(t *testing.T) {
+	t.Parallel()
+
+	// given
+	dir, err := ioutil.TempDir("", "bigcache-mmap")
+	noError(t, err)
+	defer os.RemoveAll(dir)
+	storage := NewMmapStorage(dir).(*mmapStorage)
+	cache, err := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+		Storage:            storage,
+	})
+	noError(t, err)
+
+	// when
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), blob(byte(i), 100))
+	}
+	noError(t, cache.Resize(8))
+	files, _ := ioutil.ReadDir(dir)
+
+	// then
+	assertEqual(t, 1000, cache.Len())
+	for i := 0; i < 1000; i++ {
+		value, err := cache.Get(fmt.Sprintf("key-%d", i))
+		noError(t, err)
+		assertEqual(t, blob(byte(i), 100), value)
+	}
+	assertEqual(t, 0, len(files))
+	assertEqual(t, true, cache.Stats().Reallocations > 0)
+
+	// when
+	noError(t, cache.Close())
+
+	// then
+	storage.Lock()
+	assertEqual(t, 0, len(storage.files))
+	storage.Unlock()
+	assertEqual(t, 0, cache.Len())
+}
+
+func TestMmapStorageGrowsInPlace(t *testing.T) {
+	t.Parallel()
+
+	// given
+	storage := NewMmapStorage("").(*mmapStorage)
+	buf, err := storage.Allocate(4096)
+	noError(t, err)
+	copy(buf, "contents")
+
+	// when
+	grown, err := storage.Grow(buf, 3*4096)
+
+	// then
+	noError(t, err)
+	assertEqual(t, 3*4096, len(grown))
+	assertEqual(t, []byte("contents"), grown[:8])
+	assertEqual(t, 1, len(storage.files))
+
+	// when
+	noError(t, storage.Release(grown))
+
+	// then
+	assertEqual(t, 0, len(storage.files))
+}
@@ -0,0 +1,10 @@
+//go:build go1.16
+// +build go1.16
+
+package bigcache
+
+import "os"
+
+func createTemp(dir, pattern string) (*os.File, error) {
+	return os.CreateTemp(dir, pattern)
+}
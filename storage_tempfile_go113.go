@@ -0,0 +1,13 @@
+//go:build !go1.16
+// +build !go1.16
+
+package bigcache
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func createTemp(dir, pattern string) (*os.File, error) {
+	return ioutil.TempFile(dir, pattern)
+}
@@ -0,0 +1,58 @@
+package bigcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStorage is heap storage keeping track of allocated memory.
+type countingStorage struct {
+	sync.Mutex
+	allocated, released int
+}
+
+func (s *countingStorage) Allocate(size int) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.allocated += size
+	return make([]byte, size), nil
+}
+
+func (s *countingStorage) Release(buf []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.released += cap(buf)
+	return nil
+}
+
+func TestStorageIsUsedForQueues(t *testing.T) {
+	t.Parallel()
+
+	// given
+	storage := &countingStorage{}
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+		Storage:            storage,
+	})
+
+	// when
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), blob('a', 64))
+	}
+	value, err := cache.Get("key-0")
+
+	// then
+	noError(t, err)
+	assertEqual(t, blob('a', 64), value)
+	assertEqual(t, true, cache.Stats().Reallocations > 0)
+	storage.Lock()
+	defer storage.Unlock()
+	assertEqual(t, cache.Capacity(), storage.allocated-storage.released)
+}
@@ -0,0 +1,73 @@
+package bigcache
+
+import "context"
+
+// Tracer is minimal tracing interface consulted by context aware cache methods. It is deliberately small so any
+// tracing library (OpenTelemetry, OpenTracing, etc.) could be adapted with a few lines of code.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced cache operation.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End()
+}
+
+const (
+	spanGet = "bigcache.Get"
+	spanSet = "bigcache.Set"
+
+	attrShard = "bigcache.shard"
+	attrHit   = "bigcache.hit"
+	attrError = "bigcache.error"
+)
+
+// GetContext is Get which records operation using Config.Tracer if one was provided.
+func (c *BigCache) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := c.validate(key); err != nil {
+		return nil, err
+	}
+	hashedKey := c.hash.Sum64(key)
+	if c.config.Tracer == nil {
+		return c.get(key, hashedKey)
+	}
+
+	_, span := c.config.Tracer.StartSpan(ctx, spanGet)
+	defer span.End()
+
+	span.SetAttribute(attrShard, c.currentShardIndex(hashedKey))
+	data, err := c.get(key, hashedKey)
+	span.SetAttribute(attrHit, err == nil)
+	return data, err
+}
+
+// SetContext is Set which records operation using Config.Tracer if one was provided.
+func (c *BigCache) SetContext(ctx context.Context, key string, entry []byte) error {
+	if err := c.validate(key); err != nil {
+		return err
+	}
+	hashedKey := c.hash.Sum64(key)
+	if c.config.Tracer == nil {
+		return c.set(key, hashedKey, entry)
+	}
+
+	_, span := c.config.Tracer.StartSpan(ctx, spanSet)
+	defer span.End()
+
+	span.SetAttribute(attrShard, c.currentShardIndex(hashedKey))
+	err := c.set(key, hashedKey, entry)
+	if err != nil {
+		span.SetAttribute(attrError, err.Error())
+	}
+	return err
+}
+
+// currentShardIndex returns index of the shard for hash in current layout, which could change before the shard is
+// used, so it is only good for reporting.
+func (c *BigCache) currentShardIndex(hashedKey uint64) int {
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	return c.shardIndex(hashedKey)
+}
@@ -0,0 +1,115 @@
+package bigcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	s := &recordedSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func TestTracingGetSet(t *testing.T) {
+	t.Parallel()
+
+	// given
+	tracer := &recordingTracer{}
+	config := DefaultConfig(5 * time.Second)
+	config.Tracer = tracer
+	cache, _ := NewBigCache(config)
+	ctx := context.Background()
+	shard := int(cache.hash.Sum64("key") & cache.shardMask)
+
+	// when
+	err := cache.SetContext(ctx, "key", []byte("value"))
+	noError(t, err)
+	value, err := cache.GetContext(ctx, "key")
+	noError(t, err)
+	_, err = cache.GetContext(ctx, "missing")
+
+	// then
+	assertEqual(t, []byte("value"), value)
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	assertEqual(t, 3, len(tracer.spans))
+	assertEqual(t, spanSet, tracer.spans[0].name)
+	assertEqual(t, shard, tracer.spans[0].attrs[attrShard])
+	assertEqual(t, spanGet, tracer.spans[1].name)
+	assertEqual(t, true, tracer.spans[1].attrs[attrHit])
+	assertEqual(t, false, tracer.spans[2].attrs[attrHit])
+	for _, s := range tracer.spans {
+		assertEqual(t, true, s.ended)
+	}
+}
+
+func TestContextMethodsWithoutTracer(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(DefaultConfig(5 * time.Second))
+
+	// when
+	err := cache.SetContext(context.Background(), "key", []byte("value"))
+	value, err1 := cache.GetContext(context.Background(), "key")
+
+	// then
+	noError(t, err)
+	noError(t, err1)
+	assertEqual(t, []byte("value"), value)
+}
+
+func TestTracedGetRefreshesAhead(t *testing.T) {
+	t.Parallel()
+
+	// given
+	loaded := make(chan string, 1)
+	tracer := &recordingTracer{}
+	clock := &mockedClock{value: 100}
+	cache, _ := newBigCache(Config{
+		Shards:             1,
+		LifeWindow:         10 * time.Second,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		Tracer:             tracer,
+		RefreshAhead: &RefreshAhead{
+			Threshold: 0.2,
+			Loader: func(key string) ([]byte, error) {
+				loaded <- key
+				return []byte("fresh"), nil
+			},
+		},
+	}, clock)
+	cache.Set("key", []byte("stale"))
+
+	// when - close to expiration
+	clock.set(109)
+	value, err := cache.GetContext(context.Background(), "key")
+
+	// then
+	noError(t, err)
+	assertEqual(t, []byte("stale"), value)
+	assertEqual(t, "key", <-loaded)
+	assertEqual(t, 1, len(tracer.spans))
+	assertEqual(t, true, tracer.spans[0].attrs[attrHit])
+}
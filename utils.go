@@ -21,3 +21,23 @@ func convertMBToBytes(value int) int {
 func isPowerOfTwo(number int) bool {
 	return (number & (number - 1)) == 0
 }
+
+// nextPowerOfTwo returns the smallest power of two which is not less than number.
+func nextPowerOfTwo(number int) int {
+	n := 1
+	for n < number {
+		n <<= 1
+	}
+	return n
+}
+
+// mapMemory estimates number of bytes taken by map with entries of pairSize bytes each (key and value together).
+// It follows classic Go map layout: buckets keep 8 pairs along with 8 bytes of hash bits and overflow pointer
+// and are filled up to load factor of 6.5 before map grows.
+func mapMemory(entries, pairSize int) int {
+	if entries == 0 {
+		return 0
+	}
+	buckets := nextPowerOfTwo((entries*2 + 12) / 13)
+	return buckets * (8*pairSize + 16)
+}
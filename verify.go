@@ -0,0 +1,60 @@
+package bigcache
+
+import "fmt"
+
+// OrphanedEntry describes live entry kept in shard's queue which is not referenced by shard's index, so it can
+// never be read and its space is reclaimed only when it reaches the head of the queue.
+type OrphanedEntry struct {
+	Shard  int
+	Offset int
+	Hash   uint64
+}
+
+// VerifyError is returned by Verify when inconsistencies are found.
+type VerifyError struct {
+	Orphans []OrphanedEntry
+}
+
+func (e *VerifyError) Error() string {
+	o := e.Orphans[0]
+	return fmt.Sprintf("cache is inconsistent: %d orphaned entries, first in shard %d at offset %d with hash %#x",
+		len(e.Orphans), o.Shard, o.Offset, o.Hash)
+}
+
+// Verify checks internal consistency of the cache walking every shard's queue from head to tail and making sure
+// every entry which is not deleted is referenced by index. It returns VerifyError listing entries which are not.
+// NOTE: it is expensive and is meant for tests and debugging, every shard is read locked while it is checked.
+func (c *BigCache) Verify() error {
+	var orphans []OrphanedEntry
+	for i, shard := range c.getShards() {
+		for _, o := range shard.orphans() {
+			o.Shard = i
+			orphans = append(orphans, o)
+		}
+	}
+	if len(orphans) > 0 {
+		return &VerifyError{Orphans: orphans}
+	}
+	return nil
+}
+
+// orphans returns entries which are not deleted, but are not referenced by index either.
+func (s *cacheShard) orphans() []OrphanedEntry {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var orphans []OrphanedEntry
+	s.entries.walk(func(r qref) bool {
+		hash := s.entries.getHash(r)
+		if hash == 0 {
+			// tombstone
+			return true
+		}
+		if !s.indexed(hash, r) {
+			orphans = append(orphans, OrphanedEntry{Offset: r.idx(), Hash: hash})
+		}
+		return true
+	})
+	return orphans
+}
@@ -0,0 +1,60 @@
+package bigcache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyConsistentCache(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+		HardMaxCacheSize:   1,
+	})
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("key%d", i%700)
+		switch i % 3 {
+		case 0:
+			cache.Set(key, blob('a', i%300))
+		case 1:
+			cache.Append(key, blob('b', i%50))
+		default:
+			cache.Delete(key)
+		}
+	}
+
+	// then
+	noError(t, cache.Verify())
+}
+
+func TestVerifyDetectsOrphans(t *testing.T) {
+	t.Parallel()
+
+	// given
+	cache, _ := NewBigCache(Config{
+		Shards:             1,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       16,
+	})
+	cache.Set("key1", []byte("value"))
+	cache.Set("key2", []byte("value"))
+	hash := cache.hash.Sum64("key2")
+	ref := cache.shards[0].hashmap[hash]
+	delete(cache.shards[0].hashmap, hash)
+
+	// when
+	err := cache.Verify()
+
+	// then
+	var verifyErr *VerifyError
+	assertEqual(t, true, errors.As(err, &verifyErr))
+	assertEqual(t, []OrphanedEntry{{Shard: 0, Offset: ref.idx(), Hash: hash}}, verifyErr.Orphans)
+}
@@ -0,0 +1,279 @@
+package bigcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultWALSyncInterval = time.Second
+
+// WALSyncPolicy tells when write-ahead log is flushed to stable storage.
+type WALSyncPolicy int
+
+const (
+	// WALSyncInterval flushes log every Config.WALSyncInterval, at most that much of the latest changes could be lost
+	// on power failure. It is a reasonable compromise for most caches.
+	WALSyncInterval WALSyncPolicy = iota
+	// WALSyncAlways flushes log after every record. Nothing acknowledged is lost, but every write waits for the disk,
+	// which makes writes orders of magnitude slower.
+	WALSyncAlways
+	// WALSyncNever leaves flushing to operating system. Records survive process crash, but not power failure.
+	WALSyncNever
+)
+
+// writeAheadLog is an append-only file of entries written to the cache, framed the same way CacheEntry.WriteTo does.
+// Deletions are recorded as entries with flagDeleted set.
+type writeAheadLog struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	always  bool          // sync after every record
+	pending *bytes.Buffer // records appended while log is compacted, nil otherwise
+	compact sync.Mutex    // serializes compactions
+}
+
+func openWAL(path string, policy WALSyncPolicy) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &writeAheadLog{path: path, file: file, always: policy == WALSyncAlways}, nil
+}
+
+// replay calls f for every record in the log. Incomplete or corrupted record at the end of the log, i.e. left by
+// crash in the middle of write, is cut off, so new records are appended after the last good one.
+func (w *writeAheadLog) replay(f func(*CacheEntry)) error {
+	w.Lock()
+	defer w.Unlock()
+
+	r := bufio.NewReader(io.NewSectionReader(w.file, 0, 1<<62))
+	var offset int64
+	for {
+		var ce CacheEntry
+		n, err := ce.ReadFrom(r)
+		if err == io.EOF && n == 0 {
+			break
+		}
+		if err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, ErrCacheEntryCorrupted) {
+				return err
+			}
+			if err := w.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		offset += n
+		f(&ce)
+	}
+	return nil
+}
+
+// append writes entry record to the log.
+func (w *writeAheadLog) append(ce *CacheEntry) error {
+	buf, _ := ce.MarshalBinary()
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.pending != nil {
+		w.pending.Write(buf)
+	}
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	if w.always {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// appendDelete writes deletion record to the log.
+func (w *writeAheadLog) appendDelete(key []byte, hash uint64) error {
+	return w.append(&CacheEntry{Hash: hash, Key: key, flags: flagDeleted})
+}
+
+func (w *writeAheadLog) sync() error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// rewrite replaces log with records produced by snapshot. Records appended while snapshot is taken are kept after
+// them, applying some of them twice on replay is harmless as records are idempotent.
+func (w *writeAheadLog) rewrite(snapshot func(io.Writer) error) error {
+	w.compact.Lock()
+	defer w.compact.Unlock()
+
+	tmp, err := os.Create(w.path + ".compact")
+	if err != nil {
+		return err
+	}
+	w.Lock()
+	w.pending = &bytes.Buffer{}
+	w.Unlock()
+
+	bw := bufio.NewWriter(tmp)
+	err = snapshot(bw)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if err == nil {
+		_, err = w.pending.WriteTo(bw)
+	}
+	w.pending = nil
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen compacted log: %w", err)
+	}
+	_ = w.file.Close()
+	w.file = file
+	return nil
+}
+
+// openWAL opens write-ahead log configured for the cache and restores entries recorded in it.
+func (c *BigCache) openWAL() error {
+	wal, err := openWAL(c.config.WALPath, c.config.WALSync)
+	if err != nil {
+		return err
+	}
+	err = wal.replay(func(ce *CacheEntry) {
+		// entry too big for current configuration is dropped
+		_ = c.getShard(ce.Hash).restore(ce)
+	})
+	if err != nil {
+		_ = wal.close()
+		return err
+	}
+	c.wal = wal
+	for _, shard := range c.shards {
+		shard.wal = wal
+	}
+	if c.config.WALSync == WALSyncInterval {
+		interval := c.config.WALSyncInterval
+		if interval <= 0 {
+			interval = defaultWALSyncInterval
+		}
+		go c.every(interval, func() {
+			if err := wal.sync(); err != nil {
+				leveled(c.config.Logger).Errorf("unable to sync write-ahead log: %v", err)
+			}
+		})
+	}
+	if c.config.WALCompactInterval > 0 {
+		go c.every(c.config.WALCompactInterval, func() {
+			if err := c.CompactWAL(); err != nil {
+				leveled(c.config.Logger).Errorf("unable to compact write-ahead log: %v", err)
+			}
+		})
+	}
+	return nil
+}
+
+// every calls f periodically until cache is closed.
+func (c *BigCache) every(interval time.Duration, f func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f()
+		case <-c.close:
+			return
+		}
+	}
+}
+
+// CompactWAL rewrites write-ahead log so it keeps only live entries, dropping replaced and deleted ones, which keeps
+// log size bounded. Cache could be used while log is compacted, every shard is read locked only while its entries
+// are written. It does nothing when Config.WALPath is not set. See also Config.WALCompactInterval.
+func (c *BigCache) CompactWAL() error {
+	if c.wal == nil {
+		return nil
+	}
+	c.layout.RLock()
+	defer c.layout.RUnlock()
+
+	return c.wal.rewrite(func(w io.Writer) error {
+		for _, shard := range c.shards {
+			if err := shard.writeLive(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeLive writes every live entry to w.
+func (s *cacheShard) writeLive(w io.Writer) (err error) {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	s.live(func(r qref) bool {
+		var ce *CacheEntry
+		if ce, err = s.entries.get(r); err == nil {
+			_, err = ce.WriteTo(w)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// restore applies write-ahead log or snapshot record keeping entry timestamp.
+func (s *cacheShard) restore(ce *CacheEntry) error {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if prev, found, _ := s.find(ce.Key, ce.Hash); found {
+		if err := s.entries.delete(prev); err == nil {
+			s.unindex(ce.Hash, prev)
+		}
+	}
+	if ce.flags&flagDeleted != 0 {
+		return nil
+	}
+	if err := s.pushWithoutLock(ce); err != nil {
+		return err
+	}
+	if s.wal != nil {
+		return s.wal.append(ce)
+	}
+	return nil
+}
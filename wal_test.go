@@ -0,0 +1,121 @@
+package bigcache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// walConfig returns configuration with log in temporary directory, which is removed by returned function.
+func walConfig(t *testing.T) (Config, func()) {
+	dir, err := ioutil.TempDir("", "bigcache-wal")
+	noError(t, err)
+	return Config{
+		Shards:             4,
+		LifeWindow:         time.Minute,
+		MaxEntriesInWindow: 10,
+		MaxEntrySize:       256,
+		WALPath:            filepath.Join(dir, "cache.wal"),
+		WALSync:            WALSyncNever,
+	}, func() { os.RemoveAll(dir) }
+}
+
+func TestWALRestoresCache(t *testing.T) {
+	t.Parallel()
+	requireTags(t)
+
+	// given
+	config, cleanup := walConfig(t)
+	defer cleanup()
+	cache, err := NewBigCache(config)
+	noError(t, err)
+	cache.Set("key", []byte("value"))
+	cache.Set("replaced", []byte("old"))
+	cache.Set("replaced", []byte("new"))
+	cache.Set("deleted", []byte("value"))
+	cache.Delete("deleted")
+	cache.SetWithTag("tagged", nil, 7)
+	noError(t, cache.Close())
+
+	// when
+	restored, err := NewBigCache(config)
+	noError(t, err)
+	defer restored.Close()
+
+	// then
+	value, _ := restored.Get("key")
+	assertEqual(t, []byte("value"), value)
+	value, _ = restored.Get("replaced")
+	assertEqual(t, []byte("new"), value)
+	_, err = restored.Get("deleted")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	value, info, _ := restored.GetWithInfo("tagged")
+	assertEqual(t, []byte(nil), value)
+	assertEqual(t, uint16(7), info.Tag)
+	assertEqual(t, 3, restored.Len())
+}
+
+func TestWALCompaction(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config, cleanup := walConfig(t)
+	defer cleanup()
+	cache, _ := NewBigCache(config)
+	for i := 0; i < 100; i++ {
+		cache.Set("key", blob('a', 100))
+	}
+	cache.Set("deleted", []byte("value"))
+	cache.Delete("deleted")
+	before, _ := os.Stat(config.WALPath)
+
+	// when
+	err := cache.CompactWAL()
+	cache.Set("after", []byte("value"))
+	noError(t, cache.Close())
+	after, _ := os.Stat(config.WALPath)
+	restored, _ := NewBigCache(config)
+	defer restored.Close()
+
+	// then
+	noError(t, err)
+	assertEqual(t, true, after.Size() < before.Size()/50)
+	assertEqual(t, 2, restored.Len())
+	value, _ := restored.Get("key")
+	assertEqual(t, blob('a', 100), value)
+	value, _ = restored.Get("after")
+	assertEqual(t, []byte("value"), value)
+}
+
+func TestWALCutsOffTornRecord(t *testing.T) {
+	t.Parallel()
+
+	// given
+	config, cleanup := walConfig(t)
+	defer cleanup()
+	cache, _ := NewBigCache(config)
+	cache.Set("key", []byte("value"))
+	cache.Set("torn", []byte("value"))
+	noError(t, cache.Close())
+	info, _ := os.Stat(config.WALPath)
+	noError(t, os.Truncate(config.WALPath, info.Size()-3))
+
+	// when
+	restored, err := NewBigCache(config)
+	noError(t, err)
+	restored.Set("next", []byte("value"))
+	noError(t, restored.Close())
+	reopened, _ := NewBigCache(config)
+	defer reopened.Close()
+
+	// then
+	_, err = reopened.Get("torn")
+	assertEqual(t, true, errors.Is(err, ErrEntryNotFound))
+	value, _ := reopened.Get("key")
+	assertEqual(t, []byte("value"), value)
+	value, _ = reopened.Get("next")
+	assertEqual(t, []byte("value"), value)
+}